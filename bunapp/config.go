@@ -37,6 +37,46 @@ type AppConfig struct {
 	PGX struct {
 		DSN string `yaml:"dsn"`
 	} `yaml:"pgx"`
+
+	Import struct {
+		// Encoding is the charset of DGII_RNC.TXT: auto, utf-8, windows-1252
+		// or iso-8859-1.
+		Encoding string             `yaml:"encoding"`
+		Source   ImportSourceConfig `yaml:"source"`
+		// Mode is swap (validated full replacement, the default) or merge
+		// (upsert only). Only swap marks RNCs missing from the file as
+		// removed, so merge never reports removals to the change feed or
+		// webhooks.
+		Mode string `yaml:"mode"`
+		// MaxRowDelta is the maximum relative change in row count accepted
+		// by the swap mode, e.g. 0.1 for 10%.
+		MaxRowDelta float64 `yaml:"max_row_delta"`
+		// MaxRejectedLines fails the import once more lines than this are
+		// rejected. Zero means no limit.
+		MaxRejectedLines int `yaml:"max_rejected_lines"`
+		// Schedule is the cron expression (UTC) of the scheduled import run
+		// by the api command. Empty disables it.
+		Schedule string `yaml:"schedule"`
+	} `yaml:"import"`
+
+	Webhooks struct {
+		// MaxAttempts is the number of delivery attempts before a webhook
+		// delivery is moved to the dead state. Defaults to 10.
+		MaxAttempts int `yaml:"max_attempts"`
+	} `yaml:"webhooks"`
+}
+
+// ImportSourceConfig selects where the contributors import reads the DGII file
+// from.
+type ImportSourceConfig struct {
+	// Type is one of url, zip, txt or stdin. Defaults to url.
+	Type string `yaml:"type"`
+	// URL of the DGII ZIP when Type is url.
+	URL string `yaml:"url"`
+	// Path of the local file when Type is zip or txt.
+	Path string `yaml:"path"`
+	// Entry is the TXT file name inside the ZIP.
+	Entry string `yaml:"entry"`
 }
 
 func ReadConfig(fsys fs.FS, service, env string) (*AppConfig, error) {
@@ -3,7 +3,9 @@ package bunapp
 import (
 	"net/http"
 
-	"github.com/cristianuser/go-bun-webserver/httputil/httperror"
+	"my-dgii-api/httputil"
+	"my-dgii-api/httputil/httperror"
+
 	"github.com/uptrace/bunrouter"
 	"github.com/uptrace/bunrouter/extra/bunrouterotel"
 	"github.com/uptrace/bunrouter/extra/reqlog"
@@ -16,6 +18,7 @@ func (app *App) initRouter() {
 			reqlog.FromEnv(""),
 		)),
 		bunrouter.WithMiddleware(bunrouterotel.NewMiddleware()),
+		bunrouter.WithMiddleware(requestIDMiddleware),
 	)
 
 	app.apiRouter = app.router.NewGroup("/api",
@@ -32,15 +35,24 @@ func errorHandler(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 		}
 
 		httpErr := httperror.From(err)
-		if httpErr.Status != 0 {
-			w.WriteHeader(httpErr.Status)
-		}
-		_ = bunrouter.JSON(w, httpErr)
+		httpErr.Instance = req.URL.Path
+		httpErr.RequestID = httputil.RequestID(req.Context())
+		_ = httpErr.Write(w)
 
 		return err
 	}
 }
 
+// requestIDMiddleware reuses the client's X-Request-ID or generates one, echoes
+// it in the response and stores it in the request context.
+func requestIDMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		id := httputil.NewRequestID(req.Request)
+		w.Header().Set(httputil.RequestIDHeader, id)
+		return next(w, req.WithContext(httputil.WithRequestID(req.Context(), id)))
+	}
+}
+
 func corsMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 	return func(w http.ResponseWriter, req bunrouter.Request) error {
 		origin := req.Header.Get("Origin")
@@ -52,11 +64,12 @@ func corsMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 
 		h.Set("Access-Control-Allow-Origin", origin)
 		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Expose-Headers", "ETag,Link,Location,X-Request-ID")
 
 		// CORS preflight.
 		if req.Method == http.MethodOptions {
-			h.Set("Access-Control-Allow-Methods", "GET,PUT,POST,DELETE,HEAD")
-			h.Set("Access-Control-Allow-Headers", "authorization,content-type")
+			h.Set("Access-Control-Allow-Methods", "GET,PUT,PATCH,POST,DELETE,HEAD")
+			h.Set("Access-Control-Allow-Headers", "authorization,content-type,if-match")
 			h.Set("Access-Control-Max-Age", "86400")
 			return nil
 		}
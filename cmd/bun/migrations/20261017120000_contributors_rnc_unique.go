@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Las importaciones anteriores insertaban todo el registro en cada
+		// corrida. Se conserva el registro más antiguo de cada RNC.
+		_, err := db.ExecContext(ctx, `
+			DELETE FROM contributors c
+			USING contributors d
+			WHERE c.rnc = d.rnc
+			  AND (c.created_at, c.id) > (d.created_at, d.id)
+		`)
+		if err != nil {
+			return fmt.Errorf("error removing duplicated contributors: %w", err)
+		}
+
+		_, err = db.ExecContext(ctx, `
+			CREATE UNIQUE INDEX IF NOT EXISTS contributors_rnc_key ON contributors (rnc)
+		`)
+		if err != nil {
+			return fmt.Errorf("error creating contributors rnc index: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE contributors DROP CONSTRAINT IF EXISTS contributors_rnc_key;
+			DROP INDEX IF EXISTS contributors_rnc_key;
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors rnc index: %w", err)
+		}
+		return nil
+	})
+}
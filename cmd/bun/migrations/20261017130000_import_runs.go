@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"my-dgii-api/contributors"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().Model((*contributors.ImportRun)(nil)).IfNotExists().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating import_runs table: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*contributors.ImportRun)(nil)).IfExists().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs table: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE contributors
+				ADD COLUMN IF NOT EXISTS street varchar,
+				ADD COLUMN IF NOT EXISTS street_number varchar,
+				ADD COLUMN IF NOT EXISTS sector varchar,
+				ADD COLUMN IF NOT EXISTS phone varchar,
+				ADD COLUMN IF NOT EXISTS payment_regime varchar,
+				ALTER COLUMN start_date_of_operations TYPE date
+					USING nullif(start_date_of_operations, '0001-01-01 00:00:00+00')::date;
+
+			ALTER TABLE import_runs
+				ADD COLUMN IF NOT EXISTS layout varchar;
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding contributors columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE contributors
+				DROP COLUMN IF EXISTS street,
+				DROP COLUMN IF EXISTS street_number,
+				DROP COLUMN IF EXISTS sector,
+				DROP COLUMN IF EXISTS phone,
+				DROP COLUMN IF EXISTS payment_regime,
+				ALTER COLUMN start_date_of_operations TYPE timestamptz;
+
+			ALTER TABLE import_runs
+				DROP COLUMN IF EXISTS layout;
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors columns: %w", err)
+		}
+		return nil
+	})
+}
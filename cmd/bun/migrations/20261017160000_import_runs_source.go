@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs ADD COLUMN IF NOT EXISTS source varchar
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding import_runs source column: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs DROP COLUMN IF EXISTS source
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs source column: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				ADD COLUMN IF NOT EXISTS copy_duration_ms bigint,
+				ADD COLUMN IF NOT EXISTS merge_duration_ms bigint,
+				ADD COLUMN IF NOT EXISTS rows_per_second double precision
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding import_runs throughput columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				DROP COLUMN IF EXISTS copy_duration_ms,
+				DROP COLUMN IF EXISTS merge_duration_ms,
+				DROP COLUMN IF EXISTS rows_per_second
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs throughput columns: %w", err)
+		}
+		return nil
+	})
+}
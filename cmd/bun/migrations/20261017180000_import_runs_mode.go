@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				ADD COLUMN IF NOT EXISTS mode varchar,
+				ADD COLUMN IF NOT EXISTS removed bigint
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding import_runs mode columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				DROP COLUMN IF EXISTS mode,
+				DROP COLUMN IF EXISTS removed
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs mode columns: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"my-dgii-api/contributors"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*contributors.ImportRejection)(nil)).
+			IfNotExists().
+			ForeignKey(`(import_run_id) REFERENCES import_runs (id) ON DELETE CASCADE`).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating import_rejections table: %w", err)
+		}
+
+		_, err = db.NewCreateIndex().
+			Model((*contributors.ImportRejection)(nil)).
+			Index("import_rejections_run_line_idx").
+			IfNotExists().
+			Column("import_run_id", "line_number").
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating import_rejections index: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*contributors.ImportRejection)(nil)).IfExists().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error dropping import_rejections table: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				ADD COLUMN IF NOT EXISTS etag varchar,
+				ADD COLUMN IF NOT EXISTS last_modified varchar
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding import_runs download columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				DROP COLUMN IF EXISTS etag,
+				DROP COLUMN IF EXISTS last_modified
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs download columns: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				ADD COLUMN IF NOT EXISTS dry_run boolean NOT NULL DEFAULT false,
+				ADD COLUMN IF NOT EXISTS diff jsonb
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding import_runs dry run columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE import_runs
+				DROP COLUMN IF EXISTS dry_run,
+				DROP COLUMN IF EXISTS diff
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping import_runs dry run columns: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE contributors
+				ADD COLUMN IF NOT EXISTS last_seen_import_id bigint
+					REFERENCES import_runs (id) ON DELETE SET NULL,
+				ADD COLUMN IF NOT EXISTS last_seen_at timestamptz,
+				ADD COLUMN IF NOT EXISTS removed_from_registry_at timestamptz
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding contributors last seen columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE contributors
+				DROP COLUMN IF EXISTS last_seen_import_id,
+				DROP COLUMN IF EXISTS last_seen_at,
+				DROP COLUMN IF EXISTS removed_from_registry_at
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors last seen columns: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"my-dgii-api/contributors"
+
+	"github.com/uptrace/bun"
+)
+
+// contributorHistoryColumns son las columnas que se versionan.
+const contributorHistoryColumns = `business_name, commercial_name, economic_activity,
+	street, street_number, sector, phone, start_date_of_operations, state,
+	payment_regime, removed_from_registry_at`
+
+// contributorHistoryFunctionV1 es la primera versión de la función del trigger.
+// Recibe contributorHistoryColumns como %[1]s.
+const contributorHistoryFunctionV1 = `
+	CREATE OR REPLACE FUNCTION contributors_history() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP IN ('UPDATE', 'DELETE') THEN
+			UPDATE contributor_history
+			SET valid_to = current_timestamp
+			WHERE contributor_id = OLD.id AND valid_to IS NULL;
+		END IF;
+
+		IF TG_OP IN ('INSERT', 'UPDATE') THEN
+			INSERT INTO contributor_history (
+				contributor_id, rnc, %[1]s, import_run_id, valid_from
+			) VALUES (
+				NEW.id, NEW.rnc,
+				NEW.business_name, NEW.commercial_name, NEW.economic_activity,
+				NEW.street, NEW.street_number, NEW.sector, NEW.phone,
+				NEW.start_date_of_operations, NEW.state, NEW.payment_regime,
+				NEW.removed_from_registry_at,
+				-- Las importaciones siempre cambian last_seen_import_id.
+				CASE
+					WHEN TG_OP = 'INSERT' OR NEW.last_seen_import_id IS DISTINCT FROM OLD.last_seen_import_id
+					THEN NEW.last_seen_import_id
+				END,
+				current_timestamp
+			);
+		END IF;
+
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+`
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*contributors.ContributorVersion)(nil)).
+			IfNotExists().
+			ForeignKey(`(import_run_id) REFERENCES import_runs (id) ON DELETE SET NULL`).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating contributor_history table: %w", err)
+		}
+
+		_, err = db.ExecContext(ctx, fmt.Sprintf(`
+			CREATE INDEX IF NOT EXISTS contributor_history_rnc_valid_from_idx
+				ON contributor_history (rnc, valid_from);
+			CREATE UNIQUE INDEX IF NOT EXISTS contributor_history_current_idx
+				ON contributor_history (contributor_id) WHERE valid_to IS NULL;
+
+			%[5]s
+
+			DROP TRIGGER IF EXISTS contributors_history_insert_delete_trigger ON contributors;
+			CREATE TRIGGER contributors_history_insert_delete_trigger
+				AFTER INSERT OR DELETE ON contributors
+				FOR EACH ROW EXECUTE FUNCTION contributors_history();
+
+			DROP TRIGGER IF EXISTS contributors_history_trigger ON contributors;
+			CREATE TRIGGER contributors_history_trigger
+				AFTER UPDATE ON contributors
+				FOR EACH ROW
+				WHEN ((OLD.rnc, %[2]s) IS DISTINCT FROM (NEW.rnc, %[3]s))
+				EXECUTE FUNCTION contributors_history();
+
+			-- Los registros existentes empiezan su historia en created_at.
+			INSERT INTO contributor_history (contributor_id, rnc, %[1]s, import_run_id, valid_from)
+			SELECT c.id, c.rnc, %[4]s, c.last_seen_import_id, c.created_at
+			FROM contributors c
+			WHERE NOT EXISTS (
+				SELECT 1 FROM contributor_history h WHERE h.contributor_id = c.id
+			);
+		`,
+			contributorHistoryColumns,
+			prefixColumns("OLD.", contributorHistoryColumns),
+			prefixColumns("NEW.", contributorHistoryColumns),
+			prefixColumns("c.", contributorHistoryColumns),
+			fmt.Sprintf(contributorHistoryFunctionV1, contributorHistoryColumns),
+		))
+		if err != nil {
+			return fmt.Errorf("error creating contributor_history trigger: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			DROP TRIGGER IF EXISTS contributors_history_trigger ON contributors;
+			DROP TRIGGER IF EXISTS contributors_history_insert_delete_trigger ON contributors;
+			DROP FUNCTION IF EXISTS contributors_history();
+			DROP TABLE IF EXISTS contributor_history;
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributor_history table: %w", err)
+		}
+		return nil
+	})
+}
+
+func prefixColumns(prefix, columns string) string {
+	cols := strings.Split(columns, ",")
+	for i, col := range cols {
+		cols[i] = prefix + strings.TrimSpace(col)
+	}
+	return strings.Join(cols, ", ")
+}
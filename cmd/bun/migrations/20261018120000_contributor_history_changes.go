@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// contributorHistoryFunctionV2 agrega change_type a las versiones. Recibe
+// contributorHistoryColumns como %[1]s.
+const contributorHistoryFunctionV2 = `
+	CREATE OR REPLACE FUNCTION contributors_history() RETURNS trigger AS $$
+	DECLARE
+		change varchar;
+	BEGIN
+		IF TG_OP IN ('UPDATE', 'DELETE') THEN
+			UPDATE contributor_history
+			SET valid_to = current_timestamp
+			WHERE contributor_id = OLD.id AND valid_to IS NULL;
+		END IF;
+
+		IF TG_OP = 'INSERT' THEN
+			change := 'insert';
+		ELSIF TG_OP = 'UPDATE' THEN
+			IF OLD.removed_from_registry_at IS NULL AND NEW.removed_from_registry_at IS NOT NULL THEN
+				change := 'remove';
+			ELSIF OLD.removed_from_registry_at IS NOT NULL AND NEW.removed_from_registry_at IS NULL THEN
+				change := 'restore';
+			ELSE
+				change := 'update';
+			END IF;
+		END IF;
+
+		IF TG_OP IN ('INSERT', 'UPDATE') THEN
+			INSERT INTO contributor_history (
+				contributor_id, rnc, %[1]s, import_run_id, valid_from, change_type
+			) VALUES (
+				NEW.id, NEW.rnc,
+				NEW.business_name, NEW.commercial_name, NEW.economic_activity,
+				NEW.street, NEW.street_number, NEW.sector, NEW.phone,
+				NEW.start_date_of_operations, NEW.state, NEW.payment_regime,
+				NEW.removed_from_registry_at,
+				-- Las importaciones siempre cambian last_seen_import_id.
+				CASE
+					WHEN TG_OP = 'INSERT' OR NEW.last_seen_import_id IS DISTINCT FROM OLD.last_seen_import_id
+					THEN NEW.last_seen_import_id
+				END,
+				current_timestamp,
+				change
+			);
+		END IF;
+
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+`
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`
+			ALTER TABLE contributor_history
+				ADD COLUMN IF NOT EXISTS change_type varchar NOT NULL DEFAULT 'insert',
+				ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+			CREATE INDEX IF NOT EXISTS contributor_history_txid_id_idx
+				ON contributor_history (txid, id);
+
+			%s
+		`, fmt.Sprintf(contributorHistoryFunctionV2, contributorHistoryColumns)))
+		if err != nil {
+			return fmt.Errorf("error adding contributor_history change columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`
+			%s
+
+			ALTER TABLE contributor_history
+				DROP COLUMN IF EXISTS change_type,
+				DROP COLUMN IF EXISTS txid;
+		`, fmt.Sprintf(contributorHistoryFunctionV1, contributorHistoryColumns)))
+		if err != nil {
+			return fmt.Errorf("error dropping contributor_history change columns: %w", err)
+		}
+		return nil
+	})
+}
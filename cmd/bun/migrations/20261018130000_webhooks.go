@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"my-dgii-api/contributors"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*contributors.WebhookSubscription)(nil)).
+			IfNotExists().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating webhook_subscriptions table: %w", err)
+		}
+
+		_, err = db.NewCreateTable().
+			Model((*contributors.WebhookDelivery)(nil)).
+			IfNotExists().
+			ForeignKey(`(subscription_id) REFERENCES webhook_subscriptions (id) ON DELETE CASCADE`).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating webhook_deliveries table: %w", err)
+		}
+
+		_, err = db.NewCreateTable().
+			Model((*contributors.WebhookDeliveryAttempt)(nil)).
+			IfNotExists().
+			ForeignKey(`(delivery_id) REFERENCES webhook_deliveries (id) ON DELETE CASCADE`).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating webhook_delivery_attempts table: %w", err)
+		}
+
+		// El despachador empieza en el final del feed: los cambios anteriores a
+		// la migración no generan eventos.
+		_, err = db.ExecContext(ctx, `
+			CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+				ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+			CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx
+				ON webhook_deliveries (subscription_id, id);
+			CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_idx
+				ON webhook_delivery_attempts (delivery_id);
+
+			CREATE TABLE IF NOT EXISTS webhook_feed_state (
+				id integer PRIMARY KEY CHECK (id = 1),
+				txid xid8 NOT NULL,
+				version_id bigint NOT NULL
+			);
+			INSERT INTO webhook_feed_state (id, txid, version_id)
+			SELECT 1, coalesce(last.txid, '0'::xid8), coalesce(last.id, 0)
+			FROM (SELECT 1) AS one
+			LEFT JOIN LATERAL (
+				SELECT txid, id FROM contributor_history
+				ORDER BY txid DESC, id DESC
+				LIMIT 1
+			) AS last ON true
+			ON CONFLICT (id) DO NOTHING;
+		`)
+		if err != nil {
+			return fmt.Errorf("error creating webhook indexes: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			DROP TABLE IF EXISTS webhook_feed_state;
+			DROP TABLE IF EXISTS webhook_delivery_attempts;
+			DROP TABLE IF EXISTS webhook_deliveries;
+			DROP TABLE IF EXISTS webhook_subscriptions;
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping webhook tables: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			CREATE INDEX IF NOT EXISTS contributors_state_idx
+				ON contributors (state);
+			CREATE INDEX IF NOT EXISTS contributors_economic_activity_idx
+				ON contributors (economic_activity);
+			CREATE INDEX IF NOT EXISTS contributors_payment_regime_idx
+				ON contributors (payment_regime);
+			CREATE INDEX IF NOT EXISTS contributors_start_date_of_operations_rnc_idx
+				ON contributors ((coalesce(start_date_of_operations, '-infinity'::date)), rnc);
+			CREATE INDEX IF NOT EXISTS contributors_business_name_rnc_idx
+				ON contributors (business_name, rnc);
+			CREATE INDEX IF NOT EXISTS contributors_created_at_rnc_idx
+				ON contributors (created_at, rnc);
+			CREATE INDEX IF NOT EXISTS contributors_updated_at_rnc_idx
+				ON contributors (updated_at, rnc)
+		`)
+		if err != nil {
+			return fmt.Errorf("error creating contributors list indexes: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS contributors_state_idx;
+			DROP INDEX IF EXISTS contributors_economic_activity_idx;
+			DROP INDEX IF EXISTS contributors_payment_regime_idx;
+			DROP INDEX IF EXISTS contributors_start_date_of_operations_rnc_idx;
+			DROP INDEX IF EXISTS contributors_business_name_rnc_idx;
+			DROP INDEX IF EXISTS contributors_created_at_rnc_idx;
+			DROP INDEX IF EXISTS contributors_updated_at_rnc_idx
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors list indexes: %w", err)
+		}
+		return nil
+	})
+}
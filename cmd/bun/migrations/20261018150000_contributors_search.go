@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// unaccent no es IMMUTABLE porque depende del diccionario configurado;
+		// f_unaccent fija el diccionario para poder usarla en índices.
+		_, err := db.ExecContext(ctx, `
+			CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			CREATE EXTENSION IF NOT EXISTS unaccent;
+
+			CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text
+				LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+				AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+			CREATE INDEX IF NOT EXISTS contributors_business_name_trgm_idx
+				ON contributors USING gin (f_unaccent(lower(business_name)) gin_trgm_ops);
+			CREATE INDEX IF NOT EXISTS contributors_commercial_name_trgm_idx
+				ON contributors USING gin (f_unaccent(lower(commercial_name)) gin_trgm_ops)
+		`)
+		if err != nil {
+			return fmt.Errorf("error creating contributors search indexes: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		// Las extensiones se dejan instaladas porque otros objetos de la base de
+		// datos pueden depender de ellas.
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS contributors_business_name_trgm_idx;
+			DROP INDEX IF EXISTS contributors_commercial_name_trgm_idx;
+			DROP FUNCTION IF EXISTS f_unaccent(text)
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors search indexes: %w", err)
+		}
+		return nil
+	})
+}
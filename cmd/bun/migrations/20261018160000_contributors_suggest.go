@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Con COLLATE "C" el mismo índice sirve para LIKE 'prefijo%' y para el
+		// ORDER BY, sin importar la collation de la base de datos.
+		_, err := db.ExecContext(ctx, `
+			CREATE INDEX IF NOT EXISTS contributors_rnc_prefix_idx
+				ON contributors ((rnc COLLATE "C"))
+				WHERE removed_from_registry_at IS NULL;
+			CREATE INDEX IF NOT EXISTS contributors_business_name_prefix_idx
+				ON contributors ((f_unaccent(lower(business_name)) COLLATE "C"), rnc)
+				WHERE removed_from_registry_at IS NULL
+		`)
+		if err != nil {
+			return fmt.Errorf("error creating contributors prefix indexes: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS contributors_rnc_prefix_idx;
+			DROP INDEX IF EXISTS contributors_business_name_prefix_idx
+		`)
+		if err != nil {
+			return fmt.Errorf("error dropping contributors prefix indexes: %w", err)
+		}
+		return nil
+	})
+}
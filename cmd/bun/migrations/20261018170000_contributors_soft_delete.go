@@ -0,0 +1,124 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// contributorHistoryColumnsV3 agrega deleted_at a las columnas versionadas.
+const contributorHistoryColumnsV3 = contributorHistoryColumns + `, deleted_at`
+
+// contributorHistoryFunctionV3 versiona deleted_at y distingue el borrado
+// lógico (delete y undelete) de la baja en el registro (remove y restore).
+// Recibe contributorHistoryColumnsV3 como %[1]s.
+const contributorHistoryFunctionV3 = `
+	CREATE OR REPLACE FUNCTION contributors_history() RETURNS trigger AS $$
+	DECLARE
+		change varchar;
+	BEGIN
+		IF TG_OP IN ('UPDATE', 'DELETE') THEN
+			UPDATE contributor_history
+			SET valid_to = current_timestamp
+			WHERE contributor_id = OLD.id AND valid_to IS NULL;
+		END IF;
+
+		IF TG_OP = 'INSERT' THEN
+			change := 'insert';
+		ELSIF TG_OP = 'UPDATE' THEN
+			IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+				change := 'delete';
+			ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+				change := 'undelete';
+			ELSIF OLD.removed_from_registry_at IS NULL AND NEW.removed_from_registry_at IS NOT NULL THEN
+				change := 'remove';
+			ELSIF OLD.removed_from_registry_at IS NOT NULL AND NEW.removed_from_registry_at IS NULL THEN
+				change := 'restore';
+			ELSE
+				change := 'update';
+			END IF;
+		END IF;
+
+		IF TG_OP IN ('INSERT', 'UPDATE') THEN
+			INSERT INTO contributor_history (
+				contributor_id, rnc, %[1]s, import_run_id, valid_from, change_type
+			) VALUES (
+				NEW.id, NEW.rnc,
+				NEW.business_name, NEW.commercial_name, NEW.economic_activity,
+				NEW.street, NEW.street_number, NEW.sector, NEW.phone,
+				NEW.start_date_of_operations, NEW.state, NEW.payment_regime,
+				NEW.removed_from_registry_at, NEW.deleted_at,
+				-- Las importaciones siempre cambian last_seen_import_id.
+				CASE
+					WHEN TG_OP = 'INSERT' OR NEW.last_seen_import_id IS DISTINCT FROM OLD.last_seen_import_id
+					THEN NEW.last_seen_import_id
+				END,
+				current_timestamp,
+				change
+			);
+		END IF;
+
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+`
+
+// contributorHistoryTrigger recrea el trigger de UPDATE para las columnas
+// dadas como %[1]s y %[2]s, con los prefijos OLD. y NEW.
+const contributorHistoryTrigger = `
+	DROP TRIGGER IF EXISTS contributors_history_trigger ON contributors;
+	CREATE TRIGGER contributors_history_trigger
+		AFTER UPDATE ON contributors
+		FOR EACH ROW
+		WHEN ((OLD.rnc, %[1]s) IS DISTINCT FROM (NEW.rnc, %[2]s))
+		EXECUTE FUNCTION contributors_history();
+`
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`
+			ALTER TABLE contributors
+				ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1,
+				ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+			ALTER TABLE contributor_history
+				ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+			%s
+
+			%s
+		`,
+			fmt.Sprintf(contributorHistoryFunctionV3, contributorHistoryColumnsV3),
+			fmt.Sprintf(contributorHistoryTrigger,
+				prefixColumns("OLD.", contributorHistoryColumnsV3),
+				prefixColumns("NEW.", contributorHistoryColumnsV3)),
+		))
+		if err != nil {
+			return fmt.Errorf("error adding contributors soft delete columns: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`
+			%s
+
+			%s
+
+			ALTER TABLE contributor_history
+				DROP COLUMN IF EXISTS deleted_at;
+
+			ALTER TABLE contributors
+				DROP COLUMN IF EXISTS version,
+				DROP COLUMN IF EXISTS deleted_at;
+		`,
+			fmt.Sprintf(contributorHistoryFunctionV2, contributorHistoryColumns),
+			fmt.Sprintf(contributorHistoryTrigger,
+				prefixColumns("OLD.", contributorHistoryColumns),
+				prefixColumns("NEW.", contributorHistoryColumns)),
+		))
+		if err != nil {
+			return fmt.Errorf("error dropping contributors soft delete columns: %w", err)
+		}
+		return nil
+	})
+}
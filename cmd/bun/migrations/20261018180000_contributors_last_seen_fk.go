@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// En una base nueva la migración inicial crea last_seen_import_id desde
+		// el modelo y el ADD COLUMN IF NOT EXISTS ... REFERENCES de
+		// 20261017220000 no hace nada, así que la FK se agrega aparte.
+		_, err := db.ExecContext(ctx, `
+			DO $$
+			BEGIN
+				IF NOT EXISTS (
+					SELECT 1 FROM pg_constraint
+					WHERE conname = 'contributors_last_seen_import_id_fkey'
+						AND conrelid = 'contributors'::regclass
+				) THEN
+					ALTER TABLE contributors
+						ADD CONSTRAINT contributors_last_seen_import_id_fkey
+						FOREIGN KEY (last_seen_import_id) REFERENCES import_runs (id) ON DELETE SET NULL;
+				END IF;
+			END
+			$$
+		`)
+		if err != nil {
+			return fmt.Errorf("error adding contributors last seen foreign key: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		// La FK es parte del esquema de 20261017220000 en las bases
+		// actualizadas, así que no se quita.
+		return nil
+	})
+}
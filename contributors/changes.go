@@ -0,0 +1,123 @@
+package contributors
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var errInvalidCursor = errors.New("cursor inválido")
+
+// ChangeCursor es la posición en el feed de cambios. Las versiones se ordenan
+// por (TxID, ID) y el feed solo entrega las de transacciones anteriores a la
+// más antigua en curso, así que una versión que se confirme más tarde nunca
+// queda detrás de un cursor ya entregado.
+type ChangeCursor struct {
+	TxID uint64
+	ID   int64
+}
+
+func (c ChangeCursor) String() string {
+	if c.TxID == 0 && c.ID == 0 {
+		return ""
+	}
+	s := strconv.FormatUint(c.TxID, 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseChangeCursor interpreta un cursor devuelto por el feed. El cursor vacío
+// es el inicio del feed.
+func ParseChangeCursor(s string) (ChangeCursor, error) {
+	if s == "" {
+		return ChangeCursor{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ChangeCursor{}, errInvalidCursor
+	}
+	txid, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return ChangeCursor{}, errInvalidCursor
+	}
+
+	var c ChangeCursor
+	if c.TxID, err = strconv.ParseUint(txid, 10, 64); err != nil {
+		return ChangeCursor{}, errInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return ChangeCursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+// ChangePage es una página del feed de cambios.
+type ChangePage struct {
+	Changes []*ContributorVersion `json:"changes"`
+	// NextCursor es el cursor para pedir la página siguiente. Si no hubo
+	// cambios es el mismo que se recibió.
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// SelectChanges devuelve hasta limit versiones posteriores a since.
+func SelectChanges(
+	ctx context.Context, db bun.IDB, since ChangeCursor, limit int,
+) (*ChangePage, error) {
+	var versions []*ContributorVersion
+	err := db.NewSelect().
+		Model(&versions).
+		Where("(txid, id) > (?::xid8, ?)", strconv.FormatUint(since.TxID, 10), since.ID).
+		Where("txid < pg_snapshot_xmin(pg_current_snapshot())").
+		OrderExpr("txid ASC, id ASC").
+		Limit(limit + 1).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting contributor changes: %w", err)
+	}
+
+	page := &ChangePage{
+		Changes:    versions,
+		NextCursor: since.String(),
+	}
+	if len(versions) > limit {
+		page.Changes = versions[:limit]
+		page.HasMore = true
+	}
+	if n := len(page.Changes); n > 0 {
+		last := page.Changes[n-1]
+		page.NextCursor = ChangeCursor{TxID: last.TxID, ID: last.ID}.String()
+	}
+	return page, nil
+}
+
+// WaitForChanges hace long polling: consulta el feed cada pollInterval hasta
+// que haya cambios, pase wait o se cancele ctx.
+func WaitForChanges(
+	ctx context.Context, db bun.IDB, since ChangeCursor, limit int, wait, pollInterval time.Duration,
+) (*ChangePage, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		page, err := SelectChanges(ctx, db, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Changes) > 0 || time.Until(deadline) <= 0 {
+			return page, nil
+		}
+
+		timer := time.NewTimer(min(pollInterval, time.Until(deadline)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
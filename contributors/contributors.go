@@ -2,8 +2,11 @@ package contributors
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"time"
 
 	"my-dgii-api/bunapp"
@@ -12,61 +15,189 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// PaymentRegime es el régimen de pagos publicado por la DGII.
+type PaymentRegime string
+
+const (
+	PaymentRegimeNormal PaymentRegime = "NORMAL"
+	// PaymentRegimeRST es el Régimen Simplificado de Tributación.
+	PaymentRegimeRST PaymentRegime = "RST"
+)
+
 type Contributor struct {
-    bun.BaseModel `bun:"alias:c"`
-
-    ID                    string    `json:"id" bun:",pk"`
-    RNC                   string    `json:"rnc"`
-    BusinessName          string    `json:"business_name"`
-    CommercialName        string    `json:"commercial_name"`
-    EconomicActivity      string    `json:"economic_activity"`
-    StartDateOfOperations time.Time `json:"start_date_of_operations"`
-    State                 string    `json:"state"`
-    CreatedAt             time.Time `json:"createdAt" bun:",nullzero,notnull,default:current_timestamp"`
-    UpdatedAt             time.Time `json:"updatedAt" bun:",nullzero,notnull,default:current_timestamp"`
+	bun.BaseModel `bun:"alias:c"`
+
+	ID                    string        `json:"id" bun:",pk"`
+	RNC                   string        `json:"rnc" bun:",unique,notnull"`
+	BusinessName          string        `json:"business_name"`
+	CommercialName        string        `json:"commercial_name"`
+	EconomicActivity      string        `json:"economic_activity"`
+	Street                string        `json:"street"`
+	StreetNumber          string        `json:"street_number"`
+	Sector                string        `json:"sector"`
+	Phone                 string        `json:"phone"`
+	StartDateOfOperations time.Time     `json:"start_date_of_operations" bun:"type:date,nullzero"`
+	State                 string        `json:"state"`
+	PaymentRegime         PaymentRegime `json:"payment_regime"`
+	CreatedAt             time.Time     `json:"createdAt" bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt             time.Time     `json:"updatedAt" bun:",nullzero,notnull,default:current_timestamp"`
+
+	// LastSeenImportID y LastSeenAt son la última importación que incluyó el
+	// RNC. La importación solo los guarda cuando escribe la fila; el resto lo
+	// completa resolveLastSeen. RemovedFromRegistryAt se marca cuando una
+	// importación completa (modo swap) ya no lo incluye y se limpia si vuelve
+	// a aparecer.
+	LastSeenImportID      int64        `json:"last_seen_import_id,omitempty" bun:",nullzero"`
+	LastSeenAt            bun.NullTime `json:"last_seen_at"`
+	RemovedFromRegistryAt bun.NullTime `json:"removed_from_registry_at"`
+
+	// Version aumenta con cada cambio de los datos y es el ETag del
+	// contribuyente. DeletedAt es el borrado lógico hecho desde la API, que
+	// a diferencia de RemovedFromRegistryAt no lo revierte una importación.
+	Version   int64        `json:"version" bun:",nullzero,notnull,default:1"`
+	DeletedAt bun.NullTime `json:"deleted_at"`
 }
 
 var _ bun.BeforeAppendModelHook = (*Contributor)(nil)
 
 func (c *Contributor) BeforeAppendModel(ctx context.Context, query bun.Query) error {
-    now := time.Now().UTC()
-    switch query.(type) {
-    case *bun.InsertQuery:
-        c.CreatedAt = now
-    case *bun.UpdateQuery:
-        c.UpdatedAt = now
-    }
-    return nil
+	now := time.Now().UTC()
+	switch query.(type) {
+	case *bun.InsertQuery:
+		c.CreatedAt = now
+	case *bun.UpdateQuery:
+		c.UpdatedAt = now
+	}
+	return nil
 }
 
 func (c *Contributor) Save(ctx context.Context, db bun.IDB) error {
-    if c.ID != "" {
-        _, err := db.NewUpdate().Model(c).Where("id = ?", c.ID).Exec(ctx)
-        if err != nil {
-            log.Printf("error updating contributor: %v, id: %s", err, c.ID)
-            return fmt.Errorf("error updating contributor: %w", err)
-        }
-        return nil
-    }
-
-    c.ID = uuid.NewString()
-    _, err := db.NewInsert().Model(c).Exec(ctx)
-    if err != nil {
-        log.Printf("error inserting contributor: %v, id: %s", err, c.ID)
-        return fmt.Errorf("error inserting contributor: %w", err)
-    }
-    return nil
+	if c.ID != "" {
+		_, err := db.NewUpdate().Model(c).Where("id = ?", c.ID).Exec(ctx)
+		if err != nil {
+			log.Printf("error updating contributor: %v, id: %s", err, c.ID)
+			return fmt.Errorf("error updating contributor: %w", err)
+		}
+		return nil
+	}
+
+	c.ID = uuid.NewString()
+	_, err := db.NewInsert().Model(c).Exec(ctx)
+	if err != nil {
+		log.Printf("error inserting contributor: %v, id: %s", err, c.ID)
+		return fmt.Errorf("error inserting contributor: %w", err)
+	}
+	return nil
+}
+
+// ETag devuelve la versión del contribuyente como ETag.
+func (c *Contributor) ETag() string {
+	return `"` + strconv.FormatInt(c.Version, 10) + `"`
+}
+
+// ErrVersionConflict indica que el contribuyente cambió desde que se leyó.
+var ErrVersionConflict = errors.New("el contribuyente fue modificado por otra solicitud")
+
+// Update guarda los datos del contribuyente si su versión sigue siendo
+// version, y le asigna la nueva versión. Devuelve ErrVersionConflict si otra
+// solicitud lo cambió o lo borró antes.
+func (c *Contributor) Update(ctx context.Context, db bun.IDB, version int64) error {
+	cols := append(slices.Clone(contributorDataColumns), "updated_at", "version")
+	return c.updateVersion(ctx, db.NewUpdate().
+		Model(c).
+		Column(cols...).
+		Value("version", "c.version + 1").
+		Where("c.deleted_at IS NULL"), version)
+}
+
+// SoftDelete marca el contribuyente como borrado si su versión sigue siendo
+// version.
+func (c *Contributor) SoftDelete(ctx context.Context, db bun.IDB, version int64) error {
+	return c.updateVersion(ctx, db.NewUpdate().
+		Model(c).
+		Set("deleted_at = current_timestamp").
+		Set("updated_at = current_timestamp").
+		Set("version = c.version + 1").
+		Where("c.deleted_at IS NULL"), version)
+}
+
+// Restore revierte el borrado lógico si la versión sigue siendo version.
+func (c *Contributor) Restore(ctx context.Context, db bun.IDB, version int64) error {
+	return c.updateVersion(ctx, db.NewUpdate().
+		Model(c).
+		Set("deleted_at = NULL").
+		Set("updated_at = current_timestamp").
+		Set("version = c.version + 1").
+		Where("c.deleted_at IS NOT NULL"), version)
+}
+
+func (c *Contributor) updateVersion(ctx context.Context, q *bun.UpdateQuery, version int64) error {
+	res, err := q.
+		Where("c.id = ?", c.ID).
+		Where("c.version = ?", version).
+		Returning("version, updated_at, deleted_at").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("error updating contributor: %v, id: %s", err, c.ID)
+		return fmt.Errorf("error updating contributor: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 func SelectContributorByRNC(ctx context.Context, app *bunapp.App, rnc string) (*Contributor, error) {
-    contributor := new(Contributor)
-    err := app.DB().NewSelect().
-        Model(contributor).
-        Where("rnc = ?", rnc).
-        Scan(ctx)
-    if err != nil {
-        log.Printf("error selecting contributor by RNC: %v, rnc: %s", err, rnc)
-        return nil, fmt.Errorf("error selecting contributor by RNC: %w", err)
-    }
-    return contributor, nil
-}
\ No newline at end of file
+	contributor := new(Contributor)
+	err := app.DB().NewSelect().
+		Model(contributor).
+		Where("rnc = ?", rnc).
+		Scan(ctx)
+	if err != nil {
+		log.Printf("error selecting contributor by RNC: %v, rnc: %s", err, rnc)
+		return nil, fmt.Errorf("error selecting contributor by RNC: %w", err)
+	}
+	return contributor, nil
+}
+
+// resolveLastSeen completa LastSeenImportID y LastSeenAt de los contribuyentes
+// que la última importación swap no escribió porque no cambiaron. Como swap
+// marca como retirados los RNC que no vienen en el archivo, uno vigente que ya
+// existía cuando empezó esa corrida estaba en el archivo.
+func resolveLastSeen(ctx context.Context, db bun.IDB, contributors ...*Contributor) error {
+	if len(contributors) == 0 {
+		return nil
+	}
+	run, err := lastSwapRun(ctx, db)
+	if err != nil || run == nil {
+		return err
+	}
+	for _, c := range contributors {
+		if c.RemovedFromRegistryAt.IsZero() &&
+			c.LastSeenImportID < run.ID &&
+			c.CreatedAt.Before(run.StartedAt) {
+			c.LastSeenImportID = run.ID
+			c.LastSeenAt = run.FinishedAt
+		}
+	}
+	return nil
+}
+
+// contributorDataColumns son las columnas que provienen del archivo de la DGII
+// y que el import compara para decidir si un registro cambió.
+var contributorDataColumns = []string{
+	colBusinessName,
+	colCommercialName,
+	colEconomicActivity,
+	colStreet,
+	colStreetNumber,
+	colSector,
+	colPhone,
+	colStartDate,
+	colState,
+	colPaymentRegime,
+}
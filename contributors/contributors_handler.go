@@ -1,27 +1,34 @@
 package contributors
 
 import (
-	"archive/zip"
 	"bufio"
-	"bytes"
-	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	// Asegúrate de tener este paquete o crea uno similar
 	"my-dgii-api/bunapp"
 	"my-dgii-api/httputil"
+	"my-dgii-api/httputil/httperror"
+	"my-dgii-api/rnc"
 
-	"github.com/google/uuid"
-	"github.com/ulikunitz/xz"
 	"github.com/uptrace/bunrouter"
 )
 
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+	// maxChangesWait es la espera máxima del long polling del feed de cambios.
+	maxChangesWait      = 60 * time.Second
+	changesPollInterval = 2 * time.Second
+)
+
 type ContributorHandler struct {
 	app *bunapp.App
 }
@@ -33,37 +40,362 @@ func NewContributorHandler(app *bunapp.App) *ContributorHandler {
 	}
 }
 
-// GetContributors maneja la solicitud para obtener todos los contribuyentes.
+// GetContributors maneja la solicitud para obtener los contribuyentes por
+// páginas. Acepta filtros por state, economic_activity, payment_regime y
+// start_date_from/start_date_to, un sort de la lista permitida y se pagina con
+// limit y offset o cursor. Las páginas pedidas con cursor no incluyen
+// totalCount.
 func (h *ContributorHandler) GetContributors(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
+
+	query, err := parseContributorListQuery(req)
+	if err != nil {
+		return err
+	}
+
+	// totalCount cuenta todas las filas que cumplen los filtros. Recorre todo
+	// el registro, así que las páginas que siguen a un cursor no lo calculan:
+	// sus enlaces no lo necesitan.
 	var contributors []*Contributor
-	count, err := h.app.DB().NewSelect().
-		Model(&contributors).
-		Order("created_at DESC").
-		ScanAndCount(ctx)
+	sel := query.Filter(h.app.DB().NewSelect().Model(&contributors))
+	count := -1
+	if query.cursor == nil {
+		if count, err = sel.Count(ctx); err != nil {
+			return err
+		}
+	}
+	if err := query.Page(sel).Scan(ctx); err != nil {
+		return err
+	}
+	if err := resolveLastSeen(ctx, h.app.DB(), contributors...); err != nil {
+		return err
+	}
+
+	links := query.Links(req.URL, contributors, count)
+	var header []string
+	for _, rel := range []string{"first", "prev", "next"} {
+		if href, ok := links[rel]; ok {
+			header = append(header, fmt.Sprintf("<%s>; rel=%q", href, rel))
+		}
+	}
+	if len(header) > 0 {
+		w.Header().Set("Link", strings.Join(header, ", "))
+	}
+
+	res := bunrouter.H{
+		"rows":  contributors,
+		"links": links,
+	}
+	if count >= 0 {
+		res["totalCount"] = count
+	}
+	return bunrouter.JSON(w, res)
+}
+
+// searchQuery son los parámetros de GET /contributors/search.
+type searchQuery struct {
+	Q     string `query:"q" validate:"required,min=3,max=200"`
+	Limit int    `query:"limit" validate:"min=1"`
+	// MinScore tiene un piso porque con un umbral muy bajo el operador <% deja
+	// de descartar filas y el índice GIN no sirve: se recorre toda la tabla.
+	MinScore float64 `query:"min_score" validate:"min=0.1,max=1"`
+}
+
+// Search maneja la solicitud para buscar contribuyentes por nombre. Los
+// resultados se ordenan por score; min_score (0.1 a 1) descarta los menos
+// parecidos.
+func (h *ContributorHandler) Search(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	in := searchQuery{
+		Limit:    defaultSearchLimit,
+		MinScore: defaultSearchMinScore,
+	}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	results, err := SearchContributors(ctx, h.app.DB(), strings.TrimSpace(in.Q), in.MinScore,
+		min(in.Limit, maxSearchLimit))
 	if err != nil {
 		return err
 	}
+	found := make([]*Contributor, len(results))
+	for i, r := range results {
+		found[i] = &r.Contributor
+	}
+	if err := resolveLastSeen(ctx, h.app.DB(), found...); err != nil {
+		return err
+	}
 
 	return bunrouter.JSON(w, bunrouter.H{
-		"rows":       contributors,
-		"totalCount": count,
+		"rows": results,
 	})
 }
 
-// GetByRnc maneja la solicitud para obtener un contribuyente por RNC.
+// suggestQuery son los parámetros de GET /contributors/suggest.
+type suggestQuery struct {
+	Q     string `query:"q"`
+	Limit int    `query:"limit" validate:"min=1"`
+}
+
+// Suggest maneja la solicitud de autocompletado. q puede ser el inicio de un
+// RNC o cédula, con o sin guiones, o el inicio de la razón social.
+func (h *ContributorHandler) Suggest(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	in := suggestQuery{Limit: defaultSuggestLimit}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	q := strings.TrimSpace(in.Q)
+	if _, ok := rncPrefix(q); !ok && utf8.RuneCountInString(q) < minSuggestNameLength {
+		return httperror.InvalidField("q", "min",
+			"must be an RNC prefix or have at least %d characters", minSuggestNameLength)
+	}
+
+	suggestions, err := SuggestContributors(ctx, h.app.DB(), q, min(in.Limit, maxSuggestLimit))
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows": suggestions,
+	})
+}
+
+// lookupInput es el cuerpo JSON de la búsqueda por lote.
+type lookupInput struct {
+	RNCs []string `json:"rncs" validate:"required"`
+}
+
+// Lookup maneja la solicitud para buscar varios RNCs o cédulas a la vez. Con
+// Content-Type application/json recibe {"rncs": [...]} con hasta
+// maxLookupItems elementos y responde los resultados por valor de entrada.
+// Con application/x-ndjson recibe un RNC por línea, sin límite, y responde
+// un resultado por línea a medida que resuelve cada lote.
+func (h *ContributorHandler) Lookup(w http.ResponseWriter, req bunrouter.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" {
+		return h.lookupNDJSON(w, req)
+	}
+
+	var in lookupInput
+	if err := httputil.BindJSON(w, req, &in); err != nil {
+		return err
+	}
+	if len(in.RNCs) > maxLookupItems {
+		return httperror.BadRequest("too_many_rncs",
+			"rncs must have at most %d items; use application/x-ndjson for larger lists", maxLookupItems)
+	}
+
+	results, err := LookupContributors(req.Context(), h.app.DB(), in.RNCs)
+	if err != nil {
+		return err
+	}
+
+	byInput := make(map[string]*LookupResult, len(results))
+	for _, r := range results {
+		byInput[r.Input] = r
+	}
+	return bunrouter.JSON(w, bunrouter.H{
+		"results": byInput,
+	})
+}
+
+// lookupNDJSON resuelve el cuerpo por lotes de maxLookupItems líneas. Una
+// línea puede ser el RNC tal cual o como string JSON. Si falla a mitad de la
+// respuesta, la última línea es el error.
+func (h *ContributorHandler) lookupNDJSON(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	batch := make([]string, 0, maxLookupItems)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		results, err := LookupContributors(ctx, h.app.DB(), batch)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(make([]byte, 0, 1024), 1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			if err := json.Unmarshal([]byte(line), &line); err != nil {
+				return httperror.BadRequest("invalid_json", err.Error())
+			}
+		}
+
+		batch = append(batch, line)
+		if len(batch) == maxLookupItems {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return httperror.BadRequest("invalid_ndjson", err.Error())
+	}
+	return flush()
+}
+
+// idValidation es la respuesta de GET /validate/:id.
+type idValidation struct {
+	Input      string   `json:"input"`
+	Valid      bool     `json:"valid"`
+	Reason     string   `json:"reason,omitempty"`
+	Kind       rnc.Kind `json:"type,omitempty"`
+	Normalized string   `json:"normalized,omitempty"`
+	Formatted  string   `json:"formatted,omitempty"`
+}
+
+// Validate maneja la solicitud para validar un RNC o cédula sin consultar el
+// registro. Un identificador inválido no es un error: responde 200 con
+// valid=false y el motivo.
+func (h *ContributorHandler) Validate(w http.ResponseWriter, req bunrouter.Request) error {
+	input := req.Param("id")
+	res := &idValidation{Input: input}
+
+	id, err := rnc.Parse(input)
+	switch {
+	case err == nil:
+		res.Valid = true
+		res.Kind = id.Kind
+		res.Normalized = id.String()
+		res.Formatted = id.Formatted()
+	case errors.Is(err, rnc.ErrInvalidCharacters):
+		res.Reason = "invalid_characters"
+	case errors.Is(err, rnc.ErrInvalidLength):
+		res.Reason = "invalid_length"
+	case errors.Is(err, rnc.ErrInvalidCheckDigit):
+		res.Reason = "invalid_check_digit"
+	default:
+		return err
+	}
+
+	return bunrouter.JSON(w, res)
+}
+
+// GetByRnc maneja la solicitud para obtener un contribuyente por RNC. Con
+// as_of=YYYY-MM-DD devuelve la versión vigente en esa fecha.
 func (h *ContributorHandler) GetByRnc(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
-	rnc := req.Param("rnc")
+	number, err := rncParam(req)
+	if err != nil {
+		return err
+	}
 
-	contributor, err := SelectContributorByRNC(ctx, h.app, rnc)
+	if s := req.URL.Query().Get("as_of"); s != "" {
+		date, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return httperror.BadRequest("invalid_as_of", "as_of must be a date (YYYY-MM-DD)")
+		}
+
+		version, err := SelectContributorAsOf(ctx, h.app.DB(), number, date)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return httperror.NotFound("contributor %s not found as of %s", number, s)
+			}
+			return err
+		}
+		return bunrouter.JSON(w, version)
+	}
+
+	contributor, err := SelectContributorByRNC(ctx, h.app, number)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("contributor %s not found", number)
+		}
+		return err
+	}
+	if !contributor.DeletedAt.IsZero() {
+		return httperror.NotFound("contributor %s not found", number)
+	}
+	if err := resolveLastSeen(ctx, h.app.DB(), contributor); err != nil {
 		return err
 	}
 
+	w.Header().Set("ETag", contributor.ETag())
 	return bunrouter.JSON(w, contributor)
 }
 
+// GetHistory maneja la solicitud para obtener las versiones de un
+// contribuyente.
+func (h *ContributorHandler) GetHistory(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	number, err := rncParam(req)
+	if err != nil {
+		return err
+	}
+
+	versions, err := SelectContributorHistory(ctx, h.app.DB(), number)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return httperror.NotFound("contributor %s not found", number)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows":       versions,
+		"totalCount": len(versions),
+	})
+}
+
+// changesQuery son los parámetros de GET /contributors/changes. Wait está en
+// segundos.
+type changesQuery struct {
+	Since string `query:"since"`
+	Limit int    `query:"limit" validate:"min=1"`
+	Wait  int    `query:"wait" validate:"min=0"`
+}
+
+// GetChanges maneja la solicitud para obtener el feed de cambios a partir de
+// since. Con wait=<segundos> la solicitud espera hasta que haya cambios.
+func (h *ContributorHandler) GetChanges(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	in := changesQuery{Limit: defaultChangesLimit}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	since, err := ParseChangeCursor(in.Since)
+	if err != nil {
+		return httperror.InvalidField("since", "invalid", "must be a cursor returned by this endpoint")
+	}
+	wait := min(time.Duration(in.Wait)*time.Second, maxChangesWait)
+
+	page, err := WaitForChanges(ctx, h.app.DB(), since, min(in.Limit, maxChangesLimit), wait, changesPollInterval)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, page)
+}
+
 // GetContributor maneja la solicitud para obtener un contribuyente por ID.
 func (h *ContributorHandler) GetContributor(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
@@ -73,6 +405,9 @@ func (h *ContributorHandler) GetContributor(w http.ResponseWriter, req bunrouter
 	if err != nil {
 		return err
 	}
+	if err := resolveLastSeen(ctx, h.app.DB(), contributor); err != nil {
+		return err
+	}
 
 	return bunrouter.JSON(w, contributor)
 }
@@ -80,222 +415,291 @@ func (h *ContributorHandler) GetContributor(w http.ResponseWriter, req bunrouter
 // CreateContributor maneja la solicitud para crear un nuevo contribuyente.
 func (h *ContributorHandler) CreateContributor(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
-	var contributor Contributor
-	
-	if err := httputil.BindJSON(w, req, &contributor); err != nil {
+	var in contributorCreateInput
+
+	if err := httputil.BindJSON(w, req, &in); err != nil {
+		return err
+	}
+	// BindJSON ya validó el RNC; se guarda sin guiones.
+	contributor := Contributor{RNC: rnc.Normalize(in.RNC)}
+	in.apply(&contributor)
+
+	if err := contributor.Save(ctx, h.app.DB()); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", "/api/v1/contributors/"+contributor.RNC)
+	w.Header().Set("ETag", contributor.ETag())
+	return httputil.JSON(w, contributor, http.StatusCreated)
+}
+
+// contributorData son los datos del padrón que el cliente puede escribir. Los
+// campos que maneja el servidor, como la versión, el borrado y los de la
+// importación, no se aceptan.
+type contributorData struct {
+	BusinessName          string        `json:"business_name" validate:"required,max=255"`
+	CommercialName        string        `json:"commercial_name" validate:"max=255"`
+	EconomicActivity      string        `json:"economic_activity" validate:"max=255"`
+	Street                string        `json:"street" validate:"max=255"`
+	StreetNumber          string        `json:"street_number" validate:"max=50"`
+	Sector                string        `json:"sector" validate:"max=255"`
+	Phone                 string        `json:"phone" validate:"max=50"`
+	StartDateOfOperations time.Time     `json:"start_date_of_operations"`
+	State                 string        `json:"state" validate:"max=50"`
+	PaymentRegime         PaymentRegime `json:"payment_regime" validate:"enum=NORMAL|RST"`
+}
+
+func (in *contributorData) apply(c *Contributor) {
+	c.BusinessName = in.BusinessName
+	c.CommercialName = in.CommercialName
+	c.EconomicActivity = in.EconomicActivity
+	c.Street = in.Street
+	c.StreetNumber = in.StreetNumber
+	c.Sector = in.Sector
+	c.Phone = in.Phone
+	c.StartDateOfOperations = in.StartDateOfOperations
+	c.State = in.State
+	c.PaymentRegime = in.PaymentRegime
+}
+
+// contributorCreateInput es el cuerpo de POST /contributors.
+type contributorCreateInput struct {
+	RNC string `json:"rnc" validate:"required,rnc"`
+	contributorData
+}
+
+// contributorInput es el cuerpo de PUT /contributors/:rnc. Reemplaza todos los
+// datos del contribuyente; el RNC no se puede cambiar.
+type contributorInput struct {
+	contributorData
+
+	// Version es la versión que el cliente leyó. Es opcional si se envía
+	// If-Match.
+	Version *int64 `json:"version" validate:"min=1"`
+}
+
+// contributorPatch es el cuerpo de PATCH /contributors/:rnc. Solo cambian los
+// campos presentes.
+type contributorPatch struct {
+	BusinessName          *string        `json:"business_name" validate:"max=255"`
+	CommercialName        *string        `json:"commercial_name" validate:"max=255"`
+	EconomicActivity      *string        `json:"economic_activity" validate:"max=255"`
+	Street                *string        `json:"street" validate:"max=255"`
+	StreetNumber          *string        `json:"street_number" validate:"max=50"`
+	Sector                *string        `json:"sector" validate:"max=255"`
+	Phone                 *string        `json:"phone" validate:"max=50"`
+	StartDateOfOperations *time.Time     `json:"start_date_of_operations"`
+	State                 *string        `json:"state" validate:"max=50"`
+	PaymentRegime         *PaymentRegime `json:"payment_regime" validate:"enum=NORMAL|RST"`
+
+	Version *int64 `json:"version" validate:"min=1"`
+}
+
+func (in *contributorPatch) apply(c *Contributor) error {
+	if in.BusinessName != nil {
+		if *in.BusinessName == "" {
+			return httperror.InvalidField("business_name", "required", "is required")
+		}
+		c.BusinessName = *in.BusinessName
+	}
+	if in.CommercialName != nil {
+		c.CommercialName = *in.CommercialName
+	}
+	if in.EconomicActivity != nil {
+		c.EconomicActivity = *in.EconomicActivity
+	}
+	if in.Street != nil {
+		c.Street = *in.Street
+	}
+	if in.StreetNumber != nil {
+		c.StreetNumber = *in.StreetNumber
+	}
+	if in.Sector != nil {
+		c.Sector = *in.Sector
+	}
+	if in.Phone != nil {
+		c.Phone = *in.Phone
+	}
+	if in.StartDateOfOperations != nil {
+		c.StartDateOfOperations = *in.StartDateOfOperations
+	}
+	if in.State != nil {
+		c.State = *in.State
+	}
+	if in.PaymentRegime != nil {
+		c.PaymentRegime = *in.PaymentRegime
+	}
+	return nil
+}
+
+// UpdateContributor maneja PUT y PATCH /contributors/:rnc. Exige If-Match o
+// version para no pisar el cambio de otro usuario.
+func (h *ContributorHandler) UpdateContributor(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	contributor, err := h.selectContributor(req)
+	if err != nil {
 		return err
 	}
-    tx, err := h.app.DB().BeginTx(ctx, &sql.TxOptions{})
+	if !contributor.DeletedAt.IsZero() {
+		return httperror.New(http.StatusConflict, "contributor_deleted",
+			"contributor %s is deleted; restore it before updating", contributor.RNC)
+	}
+
+	var bodyVersion *int64
+	if req.Method == http.MethodPatch {
+		var in contributorPatch
+		if err := httputil.BindJSON(w, req, &in); err != nil {
+			return err
+		}
+		if err := in.apply(contributor); err != nil {
+			return err
+		}
+		bodyVersion = in.Version
+	} else {
+		var in contributorInput
+		if err := httputil.BindJSON(w, req, &in); err != nil {
+			return err
+		}
+		in.apply(contributor)
+		bodyVersion = in.Version
+	}
+
+	version, err := expectedVersion(req, contributor, bodyVersion, true)
 	if err != nil {
 		return err
 	}
-	if err := contributor.Save(ctx, tx); err != nil{
-		tx.Rollback()
+	if err := contributor.Update(ctx, h.app.DB(), version); err != nil {
+		return versionConflict(err, contributor)
+	}
+	if err := resolveLastSeen(ctx, h.app.DB(), contributor); err != nil {
 		return err
 	}
 
+	w.Header().Set("ETag", contributor.ETag())
 	return bunrouter.JSON(w, contributor)
 }
 
-// // UpdateContributor maneja la solicitud para actualizar un contribuyente existente.
-// func (h *ContributorHandler) UpdateContributor(w http.ResponseWriter, req bunrouter.Request) error {
-// 	ctx := req.Context()
-// 	rnc := req.Param("rnc")
-// 	contributor, err := SelectContributorByRNC(ctx, h.db, rnc)
-// 	if err != nil {
-// 		return err
-// 	}
-    
-// 	tx, err := h.db.BeginTx(ctx, &sql.TxOptions{})
-// 	if err != nil {
-// 		return err
-// 	}
-// 	if err := contributor.Save(ctx, tx); err != nil {
-// 		tx.Rollback()
-// 		return err
-// 	}
-
-// 	err = contributor.Save(ctx, h.db)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	return bunrouter.JSON(w, contributor)
-// }
-
-// // DeleteContributor maneja la solicitud para eliminar un contribuyente por ID.
-// func (h *ContributorHandler) DeleteContributor(w http.ResponseWriter, req bunrouter.Request) error {
-// 	ctx := req.Context()
-// 	id := req.Param("id")
-// 	contributor := new(Contributor)
-// 	_, err := h.db.NewDelete().Model(contributor).Where("id = ?", id).Exec(ctx)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	return bunrouter.JSON(w, bunrouter.H{
-// 		"success": "true",
-// 	})
-// }
-// ImportContributors maneja la solicitud para importar contribuyentes desde un archivo ZIP de la DGII.
-func (h *ContributorHandler) ImportContributors(w http.ResponseWriter, req bunrouter.Request) error {
-    if err := h.ImportContributorsFromDGII(); err != nil {
-        return err
-    }
-
-    return bunrouter.JSON(w, bunrouter.H{"success": "true"})
+// DeleteContributor maneja la solicitud para borrar un contribuyente. El
+// borrado es lógico: deja de aparecer en las consultas pero conserva su
+// historial y se puede restaurar. If-Match es opcional.
+func (h *ContributorHandler) DeleteContributor(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	contributor, err := h.selectContributor(req)
+	if err != nil {
+		return err
+	}
+	if !contributor.DeletedAt.IsZero() {
+		return httperror.New(http.StatusConflict, "contributor_deleted",
+			"contributor %s is already deleted", contributor.RNC)
+	}
+
+	version, err := expectedVersion(req, contributor, nil, false)
+	if err != nil {
+		return err
+	}
+	if err := contributor.SoftDelete(ctx, h.app.DB(), version); err != nil {
+		return versionConflict(err, contributor)
+	}
+
+	return httputil.JSON(w, nil, http.StatusNoContent)
+}
+
+// RestoreContributor maneja la solicitud para revertir el borrado de un
+// contribuyente. If-Match es opcional.
+func (h *ContributorHandler) RestoreContributor(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	contributor, err := h.selectContributor(req)
+	if err != nil {
+		return err
+	}
+	if contributor.DeletedAt.IsZero() {
+		return httperror.New(http.StatusConflict, "contributor_not_deleted",
+			"contributor %s is not deleted", contributor.RNC)
+	}
+
+	version, err := expectedVersion(req, contributor, nil, false)
+	if err != nil {
+		return err
+	}
+	if err := contributor.Restore(ctx, h.app.DB(), version); err != nil {
+		return versionConflict(err, contributor)
+	}
+	if err := resolveLastSeen(ctx, h.app.DB(), contributor); err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", contributor.ETag())
+	return bunrouter.JSON(w, contributor)
 }
 
-func (h *ContributorHandler) ImportContributorsFromDGII() error {
-    ctx := context.Background()
-
-    reader, err := h.getContributorFileReader()
-    if err != nil {
-        return err
-    }
-    defer reader.Close()
-
-    scanner := bufio.NewScanner(reader)
-    batchSize := 1000
-    contributors := make([]*Contributor, 0, batchSize)
-
-    for scanner.Scan() {
-        line := scanner.Text()
-        contributor, err := h.parseContributorFromLine(line)
-        if err != nil {
-            log.Printf("error al procesar la línea: %v", err)
-            continue
-        }
-        contributors = append(contributors, contributor)
-
-        if len(contributors) >= batchSize {
-            if err := h.insertContributorsBatch(ctx, contributors); err != nil {
-                return err
-            }
-            contributors = contributors[:0]
-        }
-    }
-
-    if len(contributors) > 0 {
-        if err := h.insertContributorsBatch(ctx, contributors); err != nil {
-            return err
-        }
-    }
-
-    if err := scanner.Err(); err != nil {
-        return fmt.Errorf("error al leer el archivo: %w", err)
-    }
-
-    return nil
+// selectContributor busca el contribuyente de la ruta, incluso si está
+// borrado. El RNC puede venir con guiones.
+func (h *ContributorHandler) selectContributor(req bunrouter.Request) (*Contributor, error) {
+	number, err := rncParam(req)
+	if err != nil {
+		return nil, err
+	}
+	contributor, err := SelectContributorByRNC(req.Context(), h.app, number)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, httperror.NotFound("contributor %s not found", number)
+		}
+		return nil, err
+	}
+	return contributor, nil
 }
 
-func (h *ContributorHandler) getContributorFileReader() (io.ReadCloser, error) {
-    resp, err := http.Get("https://dgii.gov.do/app/WebApps/Consultas/RNC/DGII_RNC.zip")
-    if err != nil {
-        return nil, fmt.Errorf("error al descargar el ZIP: %w", err)
-    }
-    defer resp.Body.Close()
-
-    body, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("error al leer el cuerpo de la respuesta: %w", err)
-    }
-
-    zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
-    if err != nil {
-        return nil, fmt.Errorf("error al leer el ZIP: %w", err)
-    }
-
-    var txtFile *zip.File // Declaración de txtFile
-    for _, file := range zipReader.File {
-        if file.Name == "TMP/DGII_RNC.TXT" {
-            txtFile = file // Asignación de txtFile
-            break
-        }
-    }
-
-    if txtFile == nil { // Uso de txtFile
-        return nil, fmt.Errorf("no se encontró el archivo DGII_RNC.TXT")
-    }
-
-    rc, err := txtFile.Open() // Uso de txtFile
-    if err != nil {
-        return nil, fmt.Errorf("error al abrir el archivo TXT: %w", err)
-    }
-
-    var reader io.Reader = rc
-    if strings.HasSuffix(txtFile.Name, ".xz") { // Uso de txtFile
-        xzReader, err := xz.NewReader(rc)
-        if err != nil {
-            return nil, fmt.Errorf("error al descomprimir el archivo: %w", err)
-        }
-        reader = xzReader
-    }
-
-    return reader.(io.ReadCloser), nil
+// rncParam normaliza el parámetro :rnc. Acepta los RNC del padrón con el
+// dígito verificador incorrecto, pero un valor mal formado es un 422.
+func rncParam(req bunrouter.Request) (string, error) {
+	id, _, err := parseRegistryRNC(req.Param("rnc"))
+	if err != nil {
+		return "", httperror.InvalidField("rnc", "rnc", "must be a valid RNC or cédula")
+	}
+	return id.String(), nil
 }
 
-func (h *ContributorHandler) insertContributorsBatch(ctx context.Context, contributors []*Contributor) error {
-    tx, err := h.app.DB().BeginTx(ctx, nil)
-    if err != nil {
-        return err
-    }
-    defer tx.Rollback()
-
-    for _, c := range contributors {
-        c.ID = uuid.NewString()
-        _, err := tx.NewInsert().Model(c).Exec(ctx)
-        if err != nil {
-            return err
-        }
-    }
-
-    return tx.Commit()
+// expectedVersion devuelve la versión que el cliente dice haber leído, de
+// If-Match o del cuerpo. Si no envía ninguna y required es false, usa la
+// versión actual.
+func expectedVersion(req bunrouter.Request, c *Contributor, bodyVersion *int64, required bool) (int64, error) {
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		if ifMatch == "*" {
+			return c.Version, nil
+		}
+		for _, tag := range strings.Split(ifMatch, ",") {
+			if strings.TrimSpace(tag) == c.ETag() {
+				return c.Version, nil
+			}
+		}
+		return 0, httperror.New(http.StatusPreconditionFailed, "precondition_failed",
+			"contributor %s was modified; current ETag is %s", c.RNC, c.ETag())
+	}
+
+	if bodyVersion != nil {
+		if *bodyVersion != c.Version {
+			return 0, httperror.New(http.StatusConflict, "version_conflict",
+				"contributor %s was modified; current version is %d", c.RNC, c.Version)
+		}
+		return c.Version, nil
+	}
+
+	if required {
+		return 0, httperror.New(http.StatusPreconditionRequired, "precondition_required",
+			"send an If-Match header or the version field")
+	}
+	return c.Version, nil
 }
 
-func (h *ContributorHandler) parseContributorFromLine(line string) (*Contributor, error) {
-    fields := strings.Split(line, "|")
-    if len(fields) < 10 {
-        return nil, fmt.Errorf("línea inválida: %s", line)
-    }
-
-    startDateStr := strings.TrimSpace(fields[8])
-    var startDate time.Time
-    var err error
-
-    if startDateStr != "" {
-        startDate, err = time.Parse("02/01/2006", startDateStr)
-        if err != nil {
-            return nil, fmt.Errorf("error al parsear la fecha: %w", err)
-        }
-    } else {
-        startDate = time.Time{}
-    }
-
-    return &Contributor{
-        RNC:                   fields[0],
-        BusinessName:          fields[1],
-        CommercialName:        fields[2],
-        EconomicActivity:      fields[3],
-        StartDateOfOperations: startDate,
-        State:                 fields[9],
-    }, nil
+// versionConflict convierte ErrVersionConflict, que ocurre cuando otra
+// solicitud cambió el contribuyente entre la lectura y la escritura, en 409.
+func versionConflict(err error, c *Contributor) error {
+	if errors.Is(err, ErrVersionConflict) {
+		return httperror.New(http.StatusConflict, "version_conflict",
+			"contributor %s was modified by another request", c.RNC)
+	}
+	return err
 }
-// func (h *ContributorHandler) insertOrUpdateContributor(ctx context.Context, db *bun.DB, contributor *Contributor) error {
-// 	// 1. Verificar si el contribuyente ya existe por RNC
-// 	// existingContributor, err := SelectContributorByRNC(ctx, db, contributor.RNC)
-// 	// if err != nil && err != sql.ErrNoRows {
-// 	// 	return fmt.Errorf("error al verificar el contribuyente: %w", err)
-// 	// }
-
-// 	// 2. Insertar o actualizar
-// 	// if existingContributor == nil {
-// 		// Insertar si no existe
-// 		err := contributor.Save(ctx, db)
-// 		if err != nil {
-// 			return fmt.Errorf("error al insertar el contribuyente: %w", err)
-// 		}
-// 	// }
-
-// 	return nil
-// }
@@ -0,0 +1,243 @@
+package contributors
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-dgii-api/httputil"
+	"my-dgii-api/httputil/httperror"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultContributorsLimit = 50
+	maxContributorsLimit     = 500
+	defaultContributorsSort  = "-created_at"
+)
+
+// contributorSortField es una columna por la que se puede ordenar el listado.
+// Expr no puede ser NULL para que el cursor funcione; Cast es el tipo con el
+// que se compara el valor guardado en el cursor.
+type contributorSortField struct {
+	Expr string
+	Cast string
+}
+
+var contributorSortFields = map[string]contributorSortField{
+	"rnc":           {Expr: "c.rnc", Cast: "varchar"},
+	"business_name": {Expr: "c.business_name", Cast: "varchar"},
+	"start_date_of_operations": {
+		Expr: "coalesce(c.start_date_of_operations, '-infinity'::date)",
+		Cast: "date",
+	},
+	"created_at": {Expr: "c.created_at", Cast: "timestamptz"},
+	"updated_at": {Expr: "c.updated_at", Cast: "timestamptz"},
+}
+
+// contributorListQuery son los parámetros de GET /contributors. Se pagina con
+// offset o con cursor, no con ambos; el cursor sigue funcionando aunque se
+// inserten filas entre una página y la siguiente.
+type contributorListQuery struct {
+	Limit  int    `query:"limit" validate:"min=1"`
+	Offset *int   `query:"offset" validate:"min=0"`
+	Cursor string `query:"cursor"`
+	Sort   string `query:"sort"`
+
+	States             []string  `query:"state"`
+	EconomicActivities []string  `query:"economic_activity"`
+	PaymentRegimes     []string  `query:"payment_regime"`
+	StartDateFrom      time.Time `query:"start_date_from"`
+	StartDateTo        time.Time `query:"start_date_to"`
+
+	cursor    *contributorCursor
+	sortField contributorSortField
+	desc      bool
+}
+
+// contributorCursor es la posición después de la última fila de una página:
+// el valor de la columna de orden y el RNC para desempatar.
+type contributorCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	RNC   string `json:"r"`
+}
+
+func (c *contributorCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseContributorCursor(s string) (*contributorCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := new(contributorCursor)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func parseContributorListQuery(req bunrouter.Request) (*contributorListQuery, error) {
+	q := &contributorListQuery{
+		Limit: defaultContributorsLimit,
+		Sort:  defaultContributorsSort,
+	}
+	if err := httputil.BindQuery(req, q); err != nil {
+		return nil, err
+	}
+	q.Limit = min(q.Limit, maxContributorsLimit)
+
+	name := strings.TrimPrefix(q.Sort, "-")
+	field, ok := contributorSortFields[name]
+	if !ok {
+		return nil, httperror.InvalidField("sort", "enum", "must be one of %s, optionally prefixed with -",
+			strings.Join(sortedKeys(contributorSortFields), ", "))
+	}
+	q.sortField = field
+	q.desc = strings.HasPrefix(q.Sort, "-")
+
+	if q.Cursor != "" {
+		if q.Offset != nil {
+			return nil, httperror.InvalidField("cursor", "conflict", "use either offset or cursor")
+		}
+		cursor, err := parseContributorCursor(q.Cursor)
+		if err != nil || cursor.Sort != q.Sort {
+			return nil, httperror.InvalidField("cursor", "invalid",
+				"is invalid or was created with another sort")
+		}
+		q.cursor = cursor
+	}
+
+	return q, nil
+}
+
+// offset es el offset de la solicitud, o 0 si se pagina con cursor.
+func (q *contributorListQuery) offset() int {
+	if q.Offset == nil {
+		return 0
+	}
+	return *q.Offset
+}
+
+// Filter agrega los filtros a la consulta. Los contribuyentes borrados no se
+// listan.
+func (q *contributorListQuery) Filter(sel *bun.SelectQuery) *bun.SelectQuery {
+	sel = sel.Where("c.deleted_at IS NULL")
+	if len(q.States) > 0 {
+		sel = sel.Where("c.state IN (?)", bun.In(q.States))
+	}
+	if len(q.EconomicActivities) > 0 {
+		sel = sel.Where("c.economic_activity IN (?)", bun.In(q.EconomicActivities))
+	}
+	if len(q.PaymentRegimes) > 0 {
+		sel = sel.Where("c.payment_regime IN (?)", bun.In(q.PaymentRegimes))
+	}
+	if !q.StartDateFrom.IsZero() {
+		sel = sel.Where("c.start_date_of_operations >= ?", q.StartDateFrom.Format(time.DateOnly))
+	}
+	if !q.StartDateTo.IsZero() {
+		sel = sel.Where("c.start_date_of_operations <= ?", q.StartDateTo.Format(time.DateOnly))
+	}
+	return sel
+}
+
+// Page agrega el orden y la paginación a la consulta.
+func (q *contributorListQuery) Page(sel *bun.SelectQuery) *bun.SelectQuery {
+	dir := "ASC"
+	op := ">"
+	if q.desc {
+		dir = "DESC"
+		op = "<"
+	}
+	if q.cursor != nil {
+		sel = sel.Where(fmt.Sprintf("(%s, c.rnc) %s (?::%s, ?)", q.sortField.Expr, op, q.sortField.Cast),
+			q.cursor.Value, q.cursor.RNC)
+	}
+
+	return sel.
+		OrderExpr(fmt.Sprintf("%s %s, c.rnc %s", q.sortField.Expr, dir, dir)).
+		Limit(q.Limit).
+		Offset(q.offset())
+}
+
+// nextCursor es el cursor de la página que sigue a la última fila.
+func (q *contributorListQuery) nextCursor(last *Contributor) *contributorCursor {
+	c := &contributorCursor{Sort: q.Sort, RNC: last.RNC}
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "rnc":
+		c.Value = last.RNC
+	case "business_name":
+		c.Value = last.BusinessName
+	case "start_date_of_operations":
+		if last.StartDateOfOperations.IsZero() {
+			c.Value = "-infinity"
+		} else {
+			c.Value = last.StartDateOfOperations.Format(time.DateOnly)
+		}
+	case "created_at":
+		c.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		c.Value = last.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// Links devuelve los enlaces de paginación relativos a base, conservando los
+// filtros y el orden de la solicitud.
+func (q *contributorListQuery) Links(base *url.URL, rows []*Contributor, total int) map[string]string {
+	link := func(set func(v url.Values)) string {
+		v := base.Query()
+		v.Del("offset")
+		v.Del("cursor")
+		v.Set("limit", strconv.Itoa(q.Limit))
+		set(v)
+		u := *base
+		u.RawQuery = v.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{
+		"self": base.RequestURI(),
+		"first": link(func(v url.Values) {
+			if q.Offset != nil {
+				v.Set("offset", "0")
+			}
+		}),
+	}
+
+	if q.Offset != nil {
+		offset := *q.Offset
+		if offset+len(rows) < total {
+			links["next"] = link(func(v url.Values) { v.Set("offset", strconv.Itoa(offset+q.Limit)) })
+		}
+		if offset > 0 {
+			links["prev"] = link(func(v url.Values) { v.Set("offset", strconv.Itoa(max(0, offset-q.Limit))) })
+		}
+		return links
+	}
+
+	if len(rows) == q.Limit {
+		cursor := q.nextCursor(rows[len(rows)-1]).String()
+		links["next"] = link(func(v url.Values) { v.Set("cursor", cursor) })
+	}
+	return links
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,77 @@
+package contributors
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Codificaciones soportadas para el archivo de la DGII.
+const (
+	EncodingAuto        = "auto"
+	EncodingUTF8        = "utf-8"
+	EncodingWindows1252 = "windows-1252"
+	EncodingISO88591    = "iso-8859-1"
+)
+
+// lineDecoder convierte las líneas del archivo de la DGII a UTF-8. La DGII
+// publica el archivo en Windows-1252, así que en modo auto las líneas que no son
+// UTF-8 válido se decodifican como Windows-1252.
+type lineDecoder struct {
+	encoding string
+	// dec no es seguro para uso concurrente; cada importación crea su propio
+	// lineDecoder.
+	dec *encoding.Decoder
+}
+
+func newLineDecoder(name string) (*lineDecoder, error) {
+	switch strings.ToLower(name) {
+	case "", EncodingAuto:
+		return &lineDecoder{encoding: EncodingAuto, dec: charmap.Windows1252.NewDecoder()}, nil
+	case EncodingUTF8, "utf8":
+		return &lineDecoder{encoding: EncodingUTF8}, nil
+	case EncodingWindows1252, "cp1252":
+		return &lineDecoder{encoding: EncodingWindows1252, dec: charmap.Windows1252.NewDecoder()}, nil
+	case EncodingISO88591, "latin1":
+		return &lineDecoder{encoding: EncodingISO88591, dec: charmap.ISO8859_1.NewDecoder()}, nil
+	default:
+		return nil, fmt.Errorf("codificación no soportada: %q", name)
+	}
+}
+
+// Decode devuelve la línea en UTF-8 e indica si se pudo decodificar sin
+// reemplazar caracteres.
+func (d *lineDecoder) Decode(line string) (string, bool) {
+	switch d.encoding {
+	case EncodingUTF8:
+		return decodeUTF8(line)
+	case EncodingAuto:
+		if utf8.ValidString(line) {
+			return line, true
+		}
+	}
+	return d.decodeCharmap(line)
+}
+
+func decodeUTF8(line string) (string, bool) {
+	if utf8.ValidString(line) {
+		return line, true
+	}
+	return strings.ToValidUTF8(line, string(utf8.RuneError)), false
+}
+
+func (d *lineDecoder) decodeCharmap(line string) (string, bool) {
+	s, err := d.dec.String(line)
+	if err != nil {
+		return strings.ToValidUTF8(line, string(utf8.RuneError)), false
+	}
+	// Windows-1252 deja sin definir algunos bytes y en ISO-8859-1 el rango
+	// 0x80-0x9F son caracteres de control; ninguno aparece en nombres reales.
+	clean := !strings.ContainsFunc(s, func(r rune) bool {
+		return r == utf8.RuneError || (r >= 0x80 && r <= 0x9f)
+	})
+	return s, clean
+}
@@ -0,0 +1,102 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// dgiiLocation es la zona horaria de República Dominicana, sin horario de
+// verano. Las fechas de as_of se interpretan en esta zona.
+var dgiiLocation = time.FixedZone("AST", -4*60*60)
+
+// ContributorVersion es una versión de un contribuyente, válida en el
+// intervalo [ValidFrom, ValidTo). La versión vigente no tiene ValidTo.
+//
+// Las versiones las escribe el trigger contributors_history_trigger, así que
+// quedan registrados tanto los cambios de las importaciones como los manuales.
+type ContributorVersion struct {
+	bun.BaseModel `bun:"table:contributor_history,alias:ch"`
+
+	ID            int64  `json:"id" bun:",pk,autoincrement"`
+	ContributorID string `json:"contributor_id" bun:",notnull"`
+	RNC           string `json:"rnc" bun:",notnull"`
+
+	BusinessName          string        `json:"business_name"`
+	CommercialName        string        `json:"commercial_name"`
+	EconomicActivity      string        `json:"economic_activity"`
+	Street                string        `json:"street"`
+	StreetNumber          string        `json:"street_number"`
+	Sector                string        `json:"sector"`
+	Phone                 string        `json:"phone"`
+	StartDateOfOperations time.Time     `json:"start_date_of_operations" bun:"type:date,nullzero"`
+	State                 string        `json:"state"`
+	PaymentRegime         PaymentRegime `json:"payment_regime"`
+	RemovedFromRegistryAt bun.NullTime  `json:"removed_from_registry_at"`
+	DeletedAt             bun.NullTime  `json:"deleted_at"`
+
+	// ImportRunID es la importación que produjo la versión, o 0 si fue un
+	// cambio manual.
+	ImportRunID int64        `json:"import_run_id,omitempty" bun:",nullzero"`
+	ValidFrom   time.Time    `json:"valid_from" bun:",notnull"`
+	ValidTo     bun.NullTime `json:"valid_to"`
+
+	// ChangeType es el cambio que produjo la versión.
+	ChangeType ChangeType `json:"change_type" bun:",notnull,default:'insert'"`
+	// TxID es la transacción que escribió la versión; ordena el feed de
+	// cambios.
+	TxID uint64 `json:"-" bun:"txid,type:xid8,notnull,default:pg_current_xact_id()"`
+}
+
+// ChangeType es el tipo de cambio de una versión de un contribuyente.
+type ChangeType string
+
+const (
+	ChangeTypeInsert  ChangeType = "insert"
+	ChangeTypeUpdate  ChangeType = "update"
+	ChangeTypeRemove  ChangeType = "remove"
+	ChangeTypeRestore ChangeType = "restore"
+	// ChangeTypeDelete y ChangeTypeUndelete son el borrado lógico desde la API
+	// y su reversión.
+	ChangeTypeDelete   ChangeType = "delete"
+	ChangeTypeUndelete ChangeType = "undelete"
+)
+
+// SelectContributorHistory devuelve las versiones de un RNC, de la más reciente
+// a la más antigua.
+func SelectContributorHistory(ctx context.Context, db bun.IDB, rnc string) ([]*ContributorVersion, error) {
+	var versions []*ContributorVersion
+	err := db.NewSelect().
+		Model(&versions).
+		Where("rnc = ?", rnc).
+		Order("valid_from DESC", "id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting contributor history: %w", err)
+	}
+	return versions, nil
+}
+
+// SelectContributorAsOf devuelve la versión de un RNC vigente al final del día
+// date.
+func SelectContributorAsOf(
+	ctx context.Context, db bun.IDB, rnc string, date time.Time,
+) (*ContributorVersion, error) {
+	end := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, dgiiLocation)
+
+	version := new(ContributorVersion)
+	err := db.NewSelect().
+		Model(version).
+		Where("rnc = ?", rnc).
+		Where("valid_from < ?", end).
+		Where("valid_to IS NULL OR valid_to >= ?", end).
+		Order("valid_from DESC", "id DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting contributor as of %s: %w", date.Format(time.DateOnly), err)
+	}
+	return version, nil
+}
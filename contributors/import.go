@@ -0,0 +1,502 @@
+package contributors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"my-dgii-api/bunapp"
+
+	"github.com/uptrace/bun"
+)
+
+// ImportMode indica cómo se aplica el archivo a contributors.
+type ImportMode string
+
+const (
+	// ImportModeMerge inserta y actualiza los RNC del archivo y deja intactos
+	// los que no aparecen. Sirve para archivos parciales; como no marca
+	// retirados, el feed de cambios y los webhooks no reciben bajas.
+	ImportModeMerge ImportMode = "merge"
+	// ImportModeSwap reemplaza el registro completo: el archivo se valida en la
+	// tabla temporal y, solo si pasa la validación, se aplica en una única
+	// transacción que también marca como retirados los RNC que ya no
+	// aparecen. Es el modo por defecto.
+	ImportModeSwap ImportMode = "swap"
+)
+
+// defaultMaxRowDelta es la variación máxima de filas frente a la corrida
+// anterior que acepta el modo swap cuando no se configura import.max_row_delta.
+const defaultMaxRowDelta = 0.1
+
+// maxLineLength es el largo máximo de una línea del archivo. Las líneas de la
+// DGII tienen unos cientos de bytes; una más larga se rechaza sin detener la
+// importación.
+const maxLineLength = 64 << 10
+
+// maxLoggedWarnings es la cantidad de advertencias de una corrida que se
+// escriben en el log; el resto solo se cuenta.
+const maxLoggedWarnings = 20
+
+// ImportOptions configura una importación.
+type ImportOptions struct {
+	Source ContributorSource
+	Mode   ImportMode
+	// MaxRowDelta es la variación relativa máxima de filas frente a la corrida
+	// anterior en modo swap, por ejemplo 0.1 para un 10%.
+	MaxRowDelta float64
+	// MaxRejectedLines es la cantidad máxima de líneas rechazadas antes de
+	// abortar la importación. Cero o negativo no pone límite.
+	MaxRejectedLines int
+	// Force importa el archivo aunque sea el mismo de la última importación.
+	Force bool
+	// DryRun compara el archivo con contributors sin modificarlo. El resultado
+	// queda en ImportResult.Diff.
+	DryRun bool
+}
+
+// NewImportOptions crea las opciones de importación a partir de la
+// configuración.
+func NewImportOptions(cfg *bunapp.AppConfig) (ImportOptions, error) {
+	source, err := NewContributorSource(cfg.Import.Source)
+	if err != nil {
+		return ImportOptions{}, err
+	}
+
+	opts := ImportOptions{
+		Source:           source,
+		Mode:             ImportMode(cfg.Import.Mode),
+		MaxRowDelta:      cfg.Import.MaxRowDelta,
+		MaxRejectedLines: cfg.Import.MaxRejectedLines,
+	}
+	if err := opts.init(); err != nil {
+		return ImportOptions{}, err
+	}
+	return opts, nil
+}
+
+func (opts *ImportOptions) init() error {
+	switch opts.Mode {
+	case "":
+		opts.Mode = ImportModeSwap
+	case ImportModeMerge, ImportModeSwap:
+	default:
+		return fmt.Errorf("modo de importación desconocido: %q", opts.Mode)
+	}
+	if opts.MaxRowDelta <= 0 {
+		opts.MaxRowDelta = defaultMaxRowDelta
+	}
+	return nil
+}
+
+// ImportResult resume lo que hizo una importación.
+type ImportResult struct {
+	DryRun bool        `json:"dry_run" bun:",notnull,default:false"`
+	Diff   *ImportDiff `json:"diff,omitempty" bun:"type:jsonb"`
+
+	Mode       ImportMode `json:"mode"`
+	Source     string     `json:"source"`
+	FileSize   int64      `json:"file_size"`
+	FileSHA256 string     `json:"file_sha256"`
+	// ETag y LastModified son los encabezados de la descarga, para la
+	// próxima solicitud condicional.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Layout       string `json:"layout"`
+	Encoding     string `json:"encoding"`
+	LinesRead    int    `json:"lines_read"`
+	Inserted     int    `json:"inserted"`
+	Updated      int    `json:"updated"`
+	Unchanged    int    `json:"unchanged"`
+	// Removed cuenta los RNC marcados como retirados del registro.
+	Removed int `json:"removed"`
+	Skipped int `json:"skipped"`
+	// ParseErrors cuenta todas las líneas rechazadas, que quedan guardadas en
+	// import_rejections.
+	ParseErrors int `json:"parse_errors"`
+	// EncodingErrors cuenta las líneas rechazadas porque no se pudieron
+	// convertir a UTF-8 sin reemplazar caracteres.
+	EncodingErrors int `json:"encoding_errors"`
+	// CheckDigitWarnings cuenta las líneas importadas con un RNC cuyo dígito
+	// verificador no cumple el algoritmo. La DGII emitió algunos así, por lo
+	// que no se rechazan.
+	CheckDigitWarnings int `json:"check_digit_warnings"`
+
+	CopyDurationMS  int64   `json:"copy_duration_ms"`
+	MergeDurationMS int64   `json:"merge_duration_ms"`
+	RowsPerSecond   float64 `json:"rows_per_second"`
+}
+
+// ImportContributorsFromDGII importa el registro con las opciones de la
+// configuración (import.source, import.mode).
+func (h *ContributorHandler) ImportContributorsFromDGII(
+	ctx context.Context, trigger ImportTrigger,
+) (*ImportRun, error) {
+	opts, err := NewImportOptions(h.app.Config())
+	if err != nil {
+		return nil, err
+	}
+	return h.ImportContributors(ctx, trigger, opts)
+}
+
+// ImportContributors importa el registro y deja constancia de la corrida en
+// import_runs. El error de la importación, si lo hay, también queda guardado en
+// la corrida devuelta. Toma el mismo advisory lock que ImportJobs.Start y
+// devuelve errImportRunning si otra importación lo tiene.
+func (h *ContributorHandler) ImportContributors(
+	ctx context.Context, trigger ImportTrigger, opts ImportOptions,
+) (*ImportRun, error) {
+	if err := opts.init(); err != nil {
+		return nil, err
+	}
+
+	lock, err := tryImportLock(ctx, h.app.DB())
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, errImportRunning
+	}
+	defer lock.Release(ctx)
+
+	run, err := startImportRun(ctx, h.app.DB(), trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.runImport(ctx, run, opts, new(importProgress)); err != nil {
+		return run, err
+	}
+	return run, nil
+}
+
+// runImport ejecuta la importación de una corrida ya registrada y guarda su
+// resultado.
+func (h *ContributorHandler) runImport(
+	ctx context.Context, run *ImportRun, opts ImportOptions, progress *importProgress,
+) error {
+	err := h.importContributors(ctx, run.ID, opts, &run.ImportResult, progress)
+	if finishErr := run.finish(ctx, h.app.DB(), err); finishErr != nil {
+		log.Printf("error al guardar la corrida %d: %v", run.ID, finishErr)
+	}
+	if errors.Is(err, ErrNotModified) {
+		log.Printf("importación %d omitida: %v", run.ID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("importación %d completada: %d líneas, %d insertados, %d actualizados, %d sin cambios, %d eliminados, %d omitidos, %d con errores, %d con advertencias",
+		run.ID, run.LinesRead, run.Inserted, run.Updated, run.Unchanged, run.Removed, run.Skipped, run.ParseErrors,
+		run.CheckDigitWarnings)
+
+	return nil
+}
+
+func (h *ContributorHandler) importContributors(
+	ctx context.Context,
+	runID int64,
+	opts ImportOptions,
+	result *ImportResult,
+	progress *importProgress,
+) error {
+	decoder, err := newLineDecoder(h.app.Config().Import.Encoding)
+	if err != nil {
+		return err
+	}
+	result.Encoding = decoder.encoding
+	result.Mode = opts.Mode
+	result.DryRun = opts.DryRun
+
+	last, err := lastImportedRun(ctx, h.app.DB())
+	if err != nil {
+		return err
+	}
+	if opts.Force || opts.DryRun {
+		last = nil
+	}
+
+	source := opts.Source
+	result.Source = source.String()
+	if src, ok := source.(*URLSource); ok && last != nil && last.Source == result.Source {
+		conditional := *src
+		conditional.ETag = last.ETag
+		conditional.LastModified = last.LastModified
+		source = &conditional
+	}
+
+	file, err := source.Open(ctx)
+	if errors.Is(err, ErrNotModified) {
+		// La corrida conserva los datos del archivo vigente para que la
+		// próxima también pueda ser condicional.
+		result.FileSize = last.FileSize
+		result.FileSHA256 = last.FileSHA256
+		result.ETag = last.ETag
+		result.LastModified = last.LastModified
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result.FileSize = file.Size
+	result.FileSHA256 = file.SHA256
+	result.ETag = file.ETag
+	result.LastModified = file.LastModified
+	if last != nil && file.SHA256 == last.FileSHA256 {
+		return ErrNotModified
+	}
+	progress.bytesTotal.Store(file.EntrySize)
+
+	// La tabla temporal solo existe en esta conexión.
+	conn, err := h.app.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := createStagingTable(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		if err := dropStagingTable(context.WithoutCancel(ctx), conn); err != nil {
+			log.Printf("error al eliminar la tabla %s: %v", contributorsStagingTable, err)
+		}
+	}()
+
+	rejections := newRejectionRecorder(h.app.DB(), runID)
+
+	start := time.Now()
+	staged, err := copyStagingRows(ctx, conn, func(w *stagingWriter) error {
+		return h.parseContributors(ctx, file, decoder, w, rejections, opts, result, progress)
+	})
+	if err != nil {
+		return err
+	}
+	result.CopyDurationMS = time.Since(start).Milliseconds()
+
+	mergeStart := time.Now()
+	if err := indexStagingTable(ctx, conn); err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return h.dryRunStaging(ctx, conn, opts, result, staged)
+	}
+	if opts.Mode == ImportModeSwap {
+		if err := h.validateStaging(ctx, conn, opts); err != nil {
+			return err
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	merged, err := mergeStaging(ctx, tx, runID)
+	if err != nil {
+		return err
+	}
+	if opts.Mode == ImportModeSwap {
+		removed, err := markMissingFromStaging(ctx, tx)
+		if err != nil {
+			return err
+		}
+		result.Removed = removed
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	result.MergeDurationMS = time.Since(mergeStart).Milliseconds()
+
+	result.Skipped = int(staged) - merged.Distinct
+	result.Inserted = merged.Inserted
+	result.Updated = merged.Updated
+	result.Unchanged = merged.Distinct - merged.Inserted - merged.Updated
+	if secs := time.Since(start).Seconds(); secs > 0 {
+		result.RowsPerSecond = float64(staged) / secs
+	}
+
+	return nil
+}
+
+// dryRunStaging guarda en result lo que haría la importación. En modo swap la
+// validación se hace después de comparar para que el resultado muestre los
+// cambios aunque el archivo no la pase.
+func (h *ContributorHandler) dryRunStaging(
+	ctx context.Context, conn bun.IConn, opts ImportOptions, result *ImportResult, staged int64,
+) error {
+	diff, err := diffStaging(ctx, conn, opts.Mode)
+	if err != nil {
+		return err
+	}
+	result.Diff = diff
+	result.Inserted = diff.Added
+	result.Updated = diff.Changed
+	result.Unchanged = diff.Unchanged
+	result.Removed = diff.Removed
+	result.Skipped = int(staged) - diff.Added - diff.Changed - diff.Unchanged
+
+	if opts.Mode == ImportModeSwap {
+		return h.validateStaging(ctx, conn, opts)
+	}
+	return nil
+}
+
+// parseContributors lee el archivo línea por línea, escribe en w los
+// contribuyentes válidos y guarda las líneas rechazadas. Si se rechazan más de
+// opts.MaxRejectedLines líneas la importación se aborta antes de tocar
+// contributors.
+func (h *ContributorHandler) parseContributors(
+	ctx context.Context,
+	r io.Reader,
+	decoder *lineDecoder,
+	w *stagingWriter,
+	rejections *rejectionRecorder,
+	opts ImportOptions,
+	result *ImportResult,
+	progress *importProgress,
+) (err error) {
+	// Los rechazos se guardan también cuando la importación falla, para poder
+	// ver qué la hizo fallar.
+	defer func() {
+		if flushErr := rejections.Flush(context.WithoutCancel(ctx)); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}()
+
+	reject := func(line string, lineErr *lineError) error {
+		result.ParseErrors++
+		if err := rejections.Add(ctx, result.LinesRead, line, lineErr); err != nil {
+			return err
+		}
+		if opts.MaxRejectedLines > 0 && result.ParseErrors > opts.MaxRejectedLines {
+			return fmt.Errorf("%w: más de %d líneas rechazadas",
+				ErrImportValidation, opts.MaxRejectedLines)
+		}
+		return nil
+	}
+
+	splitter := &lineSplitter{max: maxLineLength}
+	scanner := bufio.NewScanner(progress.reader(r))
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineLength+1)
+	scanner.Split(splitter.Split)
+	var layout *contributorLayout
+
+	for scanner.Scan() {
+		result.LinesRead++
+		progress.linesRead.Add(1)
+
+		if result.LinesRead%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		line, ok := decoder.Decode(scanner.Text())
+		if splitter.truncated {
+			lineErr := newLineError(RejectionLineTooLong,
+				"la línea excede el máximo de %d bytes", maxLineLength)
+			if err := reject(truncateRaw(line), lineErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !ok {
+			result.EncodingErrors++
+			lineErr := newLineError(RejectionEncoding,
+				"la línea no es %s válido", decoder.encoding)
+			if err := reject(line, lineErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if layout == nil {
+			var err error
+			layout, err = detectContributorLayout(line)
+			if err != nil {
+				return err
+			}
+			result.Layout = layout.Version
+		}
+
+		contributor, warning, err := layout.Parse(line)
+		if err != nil {
+			var lineErr *lineError
+			if !errors.As(err, &lineErr) {
+				return err
+			}
+			if err := reject(line, lineErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if warning != nil {
+			result.CheckDigitWarnings++
+			if result.CheckDigitWarnings <= maxLoggedWarnings {
+				log.Printf("línea %d: %v", result.LinesRead, warning)
+			}
+		}
+		if err := w.Write(result.LinesRead, contributor); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error al leer el archivo: %w", err)
+	}
+	return nil
+}
+
+// lineSplitter divide el archivo en líneas como bufio.ScanLines, pero en lugar
+// de fallar con bufio.ErrTooLong devuelve los primeros max bytes de una línea
+// demasiado larga con truncated en true y descarta el resto.
+type lineSplitter struct {
+	max       int
+	truncated bool
+	skipping  bool
+}
+
+func (s *lineSplitter) Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if s.skipping {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			s.skipping = false
+			return i + 1, nil, nil
+		}
+		if atEOF {
+			s.skipping = false
+		}
+		return len(data), nil, nil
+	}
+
+	advance, token, err = bufio.ScanLines(data, atEOF)
+	if advance == 0 && token == nil && len(data) > s.max {
+		s.truncated = true
+		s.skipping = true
+		return s.max, data[:s.max], nil
+	}
+	s.truncated = false
+	return advance, token, err
+}
+
+// truncateRaw acorta una línea rechazada para no guardar megabytes en
+// import_rejections.
+func truncateRaw(line string) string {
+	const maxRaw = 1 << 10
+	if len(line) <= maxRaw {
+		return line
+	}
+	return strings.ToValidUTF8(line[:maxRaw], "")
+}
@@ -0,0 +1,210 @@
+package contributors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// diffSampleSize es la cantidad de ejemplos de cada tipo de cambio que guarda
+// una simulación.
+const diffSampleSize = 10
+
+// Tipos de cambio de ContributorChange.
+const (
+	ChangeAdded   = "added"
+	ChangeChanged = "changed"
+	ChangeRemoved = "removed"
+)
+
+// ImportDiff describe lo que haría una importación sobre contributors.
+type ImportDiff struct {
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	// Fields cuenta, por columna, cuántos RNC existentes cambiarían ese valor.
+	Fields map[string]int      `json:"fields"`
+	Sample []ContributorChange `json:"sample"`
+}
+
+// ContributorChange es un ejemplo de cambio. Fields solo se llena para los
+// RNC que cambian.
+type ContributorChange struct {
+	Type         string                 `json:"type"`
+	RNC          string                 `json:"rnc"`
+	BusinessName string                 `json:"business_name"`
+	Fields       map[string]FieldChange `json:"fields,omitempty"`
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// diffStaging compara la tabla temporal con contributors sin modificarla. Los
+// RNC que no están en el archivo solo se cuentan como eliminados en modo swap.
+func diffStaging(ctx context.Context, db bun.IConn, mode ImportMode) (*ImportDiff, error) {
+	changed := make([]string, len(contributorDataColumns))
+	for i, col := range contributorDataColumns {
+		changed[i] = fmt.Sprintf("count(*) FILTER (WHERE c.rnc IS NOT NULL AND c.%[1]s IS DISTINCT FROM s.%[1]s)", col)
+	}
+
+	query := fmt.Sprintf(`
+		WITH source AS (%s)
+		SELECT
+			count(*),
+			count(*) FILTER (WHERE c.rnc IS NULL),
+			count(*) FILTER (WHERE c.rnc IS NOT NULL AND %s),
+			%s
+		FROM source s
+		LEFT JOIN contributors c ON c.rnc = s.rnc
+	`, stagingSourceQuery(), rowChangedExpr("c", "s"), strings.Join(changed, ",\n\t\t\t"))
+
+	diff := &ImportDiff{
+		Fields: make(map[string]int, len(contributorDataColumns)),
+		Sample: make([]ContributorChange, 0),
+	}
+	fields := make([]int, len(contributorDataColumns))
+	var distinct int
+	dest := []any{&distinct, &diff.Added, &diff.Changed}
+	for i := range fields {
+		dest = append(dest, &fields[i])
+	}
+	if err := db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("error al comparar %s: %w", contributorsStagingTable, err)
+	}
+	diff.Unchanged = distinct - diff.Added - diff.Changed
+	for i, col := range contributorDataColumns {
+		if fields[i] > 0 {
+			diff.Fields[col] = fields[i]
+		}
+	}
+
+	if mode == ImportModeSwap {
+		err := db.QueryRowContext(ctx, fmt.Sprintf(`
+			SELECT count(*) FROM contributors c
+			WHERE c.removed_from_registry_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.rnc = c.rnc)
+		`, contributorsStagingTable)).Scan(&diff.Removed)
+		if err != nil {
+			return nil, fmt.Errorf("error al contar los contribuyentes eliminados: %w", err)
+		}
+	}
+
+	if err := diff.loadSample(ctx, db, mode); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func (d *ImportDiff) loadSample(ctx context.Context, db bun.IConn, mode ImportMode) error {
+	queries := []string{
+		fmt.Sprintf(`
+			WITH source AS (%s)
+			SELECT '%s', s.rnc, NULL::jsonb, to_jsonb(s)
+			FROM source s
+			WHERE NOT EXISTS (SELECT 1 FROM contributors c WHERE c.rnc = s.rnc)
+			ORDER BY s.rnc
+			LIMIT %d
+		`, stagingSourceQuery(), ChangeAdded, diffSampleSize),
+		fmt.Sprintf(`
+			WITH source AS (%s)
+			SELECT '%s', s.rnc, to_jsonb(c), to_jsonb(s)
+			FROM source s
+			JOIN contributors c ON c.rnc = s.rnc
+			WHERE %s
+			ORDER BY s.rnc
+			LIMIT %d
+		`, stagingSourceQuery(), ChangeChanged, rowChangedExpr("c", "s"), diffSampleSize),
+	}
+	if mode == ImportModeSwap {
+		queries = append(queries, fmt.Sprintf(`
+			SELECT '%s', c.rnc, to_jsonb(c), NULL::jsonb
+			FROM contributors c
+			WHERE c.removed_from_registry_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.rnc = c.rnc)
+			ORDER BY c.rnc
+			LIMIT %d
+		`, ChangeRemoved, contributorsStagingTable, diffSampleSize))
+	}
+
+	for _, query := range queries {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error al obtener ejemplos de cambios: %w", err)
+		}
+		for rows.Next() {
+			var change ContributorChange
+			var oldJSON, newJSON []byte
+			if err := rows.Scan(&change.Type, &change.RNC, &oldJSON, &newJSON); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			if err := change.setValues(oldJSON, newJSON); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			d.Sample = append(d.Sample, change)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		_ = rows.Close()
+	}
+	return nil
+}
+
+func (c *ContributorChange) setValues(oldJSON, newJSON []byte) error {
+	var oldRow, newRow map[string]any
+	if oldJSON != nil {
+		if err := json.Unmarshal(oldJSON, &oldRow); err != nil {
+			return err
+		}
+	}
+	if newJSON != nil {
+		if err := json.Unmarshal(newJSON, &newRow); err != nil {
+			return err
+		}
+	}
+
+	switch c.Type {
+	case ChangeAdded:
+		c.BusinessName, _ = newRow[colBusinessName].(string)
+	case ChangeRemoved:
+		c.BusinessName, _ = oldRow[colBusinessName].(string)
+	case ChangeChanged:
+		c.BusinessName, _ = newRow[colBusinessName].(string)
+		c.Fields = make(map[string]FieldChange)
+		for _, col := range contributorDataColumns {
+			if fmt.Sprint(oldRow[col]) != fmt.Sprint(newRow[col]) {
+				c.Fields[col] = FieldChange{Old: oldRow[col], New: newRow[col]}
+			}
+		}
+	}
+	return nil
+}
+
+// stagingSourceQuery deja una fila por RNC en la tabla temporal; si un RNC se
+// repite gana la última línea, igual que en mergeStaging.
+func stagingSourceQuery() string {
+	return fmt.Sprintf(`
+		SELECT DISTINCT ON (rnc) rnc, %s
+		FROM %s
+		ORDER BY rnc, line_number DESC
+	`, strings.Join(contributorDataColumns, ", "), contributorsStagingTable)
+}
+
+// rowChangedExpr compara las columnas de datos de dos alias.
+func rowChangedExpr(current, next string) string {
+	cur := make([]string, len(contributorDataColumns))
+	nxt := make([]string, len(contributorDataColumns))
+	for i, col := range contributorDataColumns {
+		cur[i] = current + "." + col
+		nxt[i] = next + "." + col
+	}
+	return fmt.Sprintf("(%s) IS DISTINCT FROM (%s)", strings.Join(cur, ", "), strings.Join(nxt, ", "))
+}
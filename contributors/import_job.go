@@ -0,0 +1,205 @@
+package contributors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"my-dgii-api/bunapp"
+)
+
+var (
+	errImportRunning  = errors.New("ya hay una importación en curso")
+	errImportsStopped = errors.New("el servidor se está deteniendo")
+)
+
+// ImportProgress es el avance de una importación en curso.
+type ImportProgress struct {
+	LinesRead  int64   `json:"lines_read"`
+	BytesRead  int64   `json:"bytes_read"`
+	BytesTotal int64   `json:"bytes_total,omitempty"`
+	Percent    float64 `json:"percent,omitempty"`
+}
+
+// importProgress lleva el avance de una importación. Lo actualiza el goroutine
+// de la importación y lo leen los handlers HTTP.
+type importProgress struct {
+	linesRead  atomic.Int64
+	bytesRead  atomic.Int64
+	bytesTotal atomic.Int64
+}
+
+func (p *importProgress) Snapshot() *ImportProgress {
+	snap := &ImportProgress{
+		LinesRead:  p.linesRead.Load(),
+		BytesRead:  p.bytesRead.Load(),
+		BytesTotal: p.bytesTotal.Load(),
+	}
+	if snap.BytesTotal > 0 {
+		snap.Percent = min(100, float64(snap.BytesRead)*100/float64(snap.BytesTotal))
+	}
+	return snap
+}
+
+// reader cuenta los bytes leídos de r.
+func (p *importProgress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *importProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.bytesRead.Add(int64(n))
+	return n, err
+}
+
+//------------------------------------------------------------------------------
+
+// ImportJobs ejecuta importaciones en segundo plano. Los trabajos no dependen
+// de la solicitud HTTP que los creó y se cancelan cuando la aplicación se
+// detiene.
+type ImportJobs struct {
+	app     *bunapp.App
+	handler *ContributorHandler
+
+	mu      sync.Mutex
+	jobs    map[int64]*importJob
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type importJob struct {
+	run      *ImportRun
+	progress *importProgress
+	cancel   context.CancelFunc
+}
+
+// NewImportJobs crea un ImportJobs y lo detiene junto con la aplicación.
+func NewImportJobs(app *bunapp.App, handler *ContributorHandler) *ImportJobs {
+	jobs := &ImportJobs{
+		app:     app,
+		handler: handler,
+		jobs:    make(map[int64]*importJob),
+	}
+	app.OnStop("contributors.importJobs", func(ctx context.Context, _ *bunapp.App) error {
+		return jobs.Stop(ctx)
+	})
+	return jobs
+}
+
+// Start registra la corrida y lanza la importación en segundo plano. Solo se
+// permite una importación a la vez: por proceso y, con el advisory lock, entre
+// todas las instancias que comparten la base de datos.
+func (j *ImportJobs) Start(trigger ImportTrigger, opts ImportOptions) (*ImportRun, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stopped {
+		return nil, errImportsStopped
+	}
+	if len(j.jobs) > 0 {
+		return nil, errImportRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(j.app.Context()))
+
+	lock, err := tryImportLock(ctx, j.app.DB())
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if lock == nil {
+		cancel()
+		return nil, errImportRunning
+	}
+
+	run, err := startImportRun(ctx, j.app.DB(), trigger)
+	if err != nil {
+		lock.Release(ctx)
+		cancel()
+		return nil, err
+	}
+
+	job := &importJob{
+		run:      run,
+		progress: new(importProgress),
+		cancel:   cancel,
+	}
+	j.jobs[run.ID] = job
+	// El goroutine modifica run mientras importa.
+	started := *run
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		defer cancel()
+		defer lock.Release(ctx)
+
+		if err := j.handler.runImport(ctx, run, opts, job.progress); err != nil {
+			log.Printf("importación %d terminó con error: %v", run.ID, err)
+		}
+
+		j.mu.Lock()
+		delete(j.jobs, run.ID)
+		j.mu.Unlock()
+	}()
+
+	return &started, nil
+}
+
+// Progress devuelve el avance de la corrida si se está ejecutando en este
+// proceso.
+func (j *ImportJobs) Progress(id int64) (*ImportProgress, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.progress.Snapshot(), true
+}
+
+// Cancel cancela la corrida si se está ejecutando en este proceso.
+func (j *ImportJobs) Cancel(id int64) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return false
+	}
+	job.cancel()
+	return true
+}
+
+// Stop cancela las importaciones en curso y espera a que terminen de guardar
+// su corrida.
+func (j *ImportJobs) Stop(ctx context.Context) error {
+	j.mu.Lock()
+	j.stopped = true
+	for _, job := range j.jobs {
+		job.cancel()
+	}
+	j.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
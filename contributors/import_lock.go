@@ -0,0 +1,49 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/uptrace/bun"
+)
+
+// importLockKey identifica el advisory lock de PostgreSQL que comparten todas
+// las instancias de la API. Quien lo tiene es la única que puede importar.
+const importLockKey int64 = 0x64676969_726e63 // "dgiirnc"
+
+// importLock es un advisory lock de sesión. Se mantiene mientras la conexión
+// siga abierta, así que si el proceso muere PostgreSQL lo libera solo.
+type importLock struct {
+	conn bun.Conn
+}
+
+// tryImportLock intenta tomar el lock sin esperar. Devuelve nil si otra
+// instancia lo tiene.
+func tryImportLock(ctx context.Context, db *bun.DB) (*importLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(?)", importLockKey).Scan(&locked)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error al tomar el lock de importación: %w", err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, nil
+	}
+	return &importLock{conn: conn}, nil
+}
+
+// Release libera el lock y devuelve la conexión al pool.
+func (l *importLock) Release(ctx context.Context) {
+	_, err := l.conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock(?)", importLockKey)
+	if err != nil {
+		log.Printf("error al liberar el lock de importación: %v", err)
+	}
+	_ = l.conn.Close()
+}
@@ -0,0 +1,142 @@
+package contributors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ImportTrigger indica quién inició una importación.
+type ImportTrigger string
+
+const (
+	ImportTriggerHTTP ImportTrigger = "http"
+	ImportTriggerCLI  ImportTrigger = "cli"
+	ImportTriggerCron ImportTrigger = "cron"
+)
+
+// ImportStatus es el estado de una corrida de importación.
+type ImportStatus string
+
+const (
+	ImportStatusRunning   ImportStatus = "running"
+	ImportStatusSucceeded ImportStatus = "succeeded"
+	ImportStatusFailed    ImportStatus = "failed"
+	ImportStatusCanceled  ImportStatus = "canceled"
+	// ImportStatusNotModified indica que el archivo era el mismo de la última
+	// importación y no se procesó.
+	ImportStatusNotModified ImportStatus = "not_modified"
+)
+
+// ImportRun es el registro de una corrida de importación del padrón de la DGII.
+type ImportRun struct {
+	bun.BaseModel `bun:"table:import_runs,alias:ir"`
+
+	ID         int64         `json:"id" bun:",pk,autoincrement"`
+	Trigger    ImportTrigger `json:"trigger" bun:",notnull"`
+	Status     ImportStatus  `json:"status" bun:",notnull"`
+	StartedAt  time.Time     `json:"started_at" bun:",notnull"`
+	FinishedAt bun.NullTime  `json:"finished_at"`
+	Error      string        `json:"error,omitempty"`
+
+	ImportResult
+
+	// Progress solo se llena mientras la corrida se ejecuta en este proceso.
+	Progress *ImportProgress `json:"progress,omitempty" bun:"-"`
+}
+
+func startImportRun(ctx context.Context, db bun.IDB, trigger ImportTrigger) (*ImportRun, error) {
+	run := &ImportRun{
+		Trigger:   trigger,
+		Status:    ImportStatusRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if _, err := db.NewInsert().Model(run).Exec(ctx); err != nil {
+		log.Printf("error inserting import run: %v", err)
+		return nil, fmt.Errorf("error inserting import run: %w", err)
+	}
+	return run, nil
+}
+
+// finish guarda el resultado final de la corrida. Se usa un contexto sin
+// cancelación para que la corrida quede cerrada aunque ctx ya haya expirado.
+func (r *ImportRun) finish(ctx context.Context, db bun.IDB, runErr error) error {
+	r.FinishedAt = bun.NullTime{Time: time.Now().UTC()}
+	switch {
+	case runErr == nil:
+		r.Status = ImportStatusSucceeded
+	case errors.Is(runErr, ErrNotModified):
+		r.Status = ImportStatusNotModified
+	case errors.Is(runErr, context.Canceled):
+		r.Status = ImportStatusCanceled
+		r.Error = runErr.Error()
+	default:
+		r.Status = ImportStatusFailed
+		r.Error = runErr.Error()
+	}
+
+	_, err := db.NewUpdate().
+		Model(r).
+		WherePK().
+		Exec(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("error updating import run: %w", err)
+	}
+	return nil
+}
+
+// lastImportedRun devuelve la corrida más reciente cuyo archivo quedó
+// aplicado a contributors, o nil si no hay ninguna.
+func lastImportedRun(ctx context.Context, db bun.IDB) (*ImportRun, error) {
+	run := new(ImportRun)
+	err := db.NewSelect().
+		Model(run).
+		Where("status IN (?)", bun.In([]ImportStatus{ImportStatusSucceeded, ImportStatusNotModified})).
+		Where("file_sha256 <> ''").
+		Where("NOT dry_run").
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error selecting last import run: %w", err)
+	}
+	return run, nil
+}
+
+// lastSwapRun devuelve la última importación swap aplicada a contributors, o
+// nil si no hay ninguna.
+func lastSwapRun(ctx context.Context, db bun.IDB) (*ImportRun, error) {
+	run := new(ImportRun)
+	err := db.NewSelect().
+		Model(run).
+		Column("id", "started_at", "finished_at").
+		Where("status = ?", ImportStatusSucceeded).
+		Where("mode = ?", ImportModeSwap).
+		Where("NOT dry_run").
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error selecting last swap import run: %w", err)
+	}
+	return run, nil
+}
+
+func SelectImportRun(ctx context.Context, db bun.IDB, id int64) (*ImportRun, error) {
+	run := new(ImportRun)
+	if err := db.NewSelect().Model(run).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error selecting import run: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,194 @@
+package contributors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"my-dgii-api/bunapp"
+	"my-dgii-api/httputil"
+	"my-dgii-api/httputil/httperror"
+
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	defaultImportRunsLimit = 20
+	maxImportRunsLimit     = 100
+	defaultRejectionsLimit = 100
+	maxRejectionsLimit     = 1000
+)
+
+type ImportRunHandler struct {
+	app  *bunapp.App
+	jobs *ImportJobs
+}
+
+// NewImportRunHandler crea un nuevo ImportRunHandler.
+func NewImportRunHandler(app *bunapp.App, jobs *ImportJobs) *ImportRunHandler {
+	return &ImportRunHandler{
+		app:  app,
+		jobs: jobs,
+	}
+}
+
+// Start maneja la solicitud para importar contribuyentes desde un archivo ZIP
+// de la DGII. La importación corre en segundo plano; la respuesta apunta a la
+// corrida que se puede consultar para ver el avance. Con dry_run=true solo se
+// compara el archivo con contributors y el resultado queda en la corrida.
+func (h *ImportRunHandler) Start(w http.ResponseWriter, req bunrouter.Request) error {
+	opts, err := NewImportOptions(h.app.Config())
+	if err != nil {
+		return err
+	}
+	var in struct {
+		DryRun bool `query:"dry_run"`
+	}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+	opts.DryRun = in.DryRun
+
+	run, err := h.jobs.Start(ImportTriggerHTTP, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, errImportRunning):
+			return httperror.New(http.StatusConflict, "import_running", err.Error())
+		case errors.Is(err, errImportsStopped):
+			return httperror.New(http.StatusServiceUnavailable, "shutting_down", err.Error())
+		}
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/imports/%d", run.ID))
+	return httputil.JSON(w, run, http.StatusAccepted)
+}
+
+// Cancel maneja la solicitud para cancelar una importación en curso.
+func (h *ImportRunHandler) Cancel(w http.ResponseWriter, req bunrouter.Request) error {
+	id, err := req.Params().Int64("id")
+	if err != nil {
+		return httperror.BadRequest("invalid_id", "id must be an integer")
+	}
+
+	if !h.jobs.Cancel(id) {
+		return httperror.New(http.StatusConflict, "import_not_running",
+			"import run %d is not running", id)
+	}
+
+	return httputil.JSON(w, bunrouter.H{"success": "true"}, http.StatusAccepted)
+}
+
+// importRunsQuery son los parámetros de GET /imports.
+type importRunsQuery struct {
+	Limit int `query:"limit" validate:"min=1"`
+}
+
+// List maneja la solicitud para obtener las últimas corridas de importación.
+func (h *ImportRunHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	in := importRunsQuery{Limit: defaultImportRunsLimit}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	var runs []*ImportRun
+	count, err := h.app.DB().NewSelect().
+		Model(&runs).
+		Order("id DESC").
+		Limit(min(in.Limit, maxImportRunsLimit)).
+		ScanAndCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		run.Progress, _ = h.jobs.Progress(run.ID)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows":       runs,
+		"totalCount": count,
+	})
+}
+
+// Get maneja la solicitud para obtener una corrida de importación por ID.
+func (h *ImportRunHandler) Get(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	id, err := req.Params().Int64("id")
+	if err != nil {
+		return httperror.BadRequest("invalid_id", "id must be an integer")
+	}
+
+	run, err := SelectImportRun(ctx, h.app.DB(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("import run %d not found", id)
+		}
+		return err
+	}
+	run.Progress, _ = h.jobs.Progress(run.ID)
+
+	return bunrouter.JSON(w, run)
+}
+
+// rejectionsQuery son los parámetros de GET /imports/:id/rejections. After es
+// el último número de línea recibido.
+type rejectionsQuery struct {
+	Limit  int    `query:"limit" validate:"min=1"`
+	After  int    `query:"after" validate:"min=0"`
+	Reason string `query:"reason"`
+}
+
+// Rejections maneja la solicitud para obtener las líneas rechazadas de una
+// corrida. Se pagina con after, el último número de línea recibido, y se puede
+// filtrar por reason.
+func (h *ImportRunHandler) Rejections(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	id, err := req.Params().Int64("id")
+	if err != nil {
+		return httperror.BadRequest("invalid_id", "id must be an integer")
+	}
+
+	in := rejectionsQuery{Limit: defaultRejectionsLimit}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	if _, err := SelectImportRun(ctx, h.app.DB(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("import run %d not found", id)
+		}
+		return err
+	}
+
+	var rejections []*ImportRejection
+	q := h.app.DB().NewSelect().
+		Model(&rejections).
+		Where("import_run_id = ?", id)
+	if in.Reason != "" {
+		q = q.Where("reason = ?", in.Reason)
+	}
+
+	// totalCount no depende del cursor.
+	count, err := q.Count(ctx)
+	if err != nil {
+		return err
+	}
+	err = q.Where("line_number > ?", in.After).
+		OrderExpr("line_number ASC").
+		Limit(min(in.Limit, maxRejectionsLimit)).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows":       rejections,
+		"totalCount": count,
+	})
+}
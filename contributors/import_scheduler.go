@@ -0,0 +1,58 @@
+package contributors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"my-dgii-api/bunapp"
+
+	"github.com/go-co-op/gocron"
+)
+
+// startImportScheduler programa la importación del registro según
+// import.schedule. Todas las instancias de la API programan la tarea, pero solo
+// la que toma el advisory lock en ImportJobs.Start la ejecuta; las demás la
+// omiten sin registrar una corrida.
+func startImportScheduler(app *bunapp.App, jobs *ImportJobs) error {
+	spec := app.Config().Import.Schedule
+	if spec == "" {
+		log.Println("import.schedule no está configurado; no se programan importaciones")
+		return nil
+	}
+
+	s := gocron.NewScheduler(time.UTC)
+	s.SingletonModeAll()
+
+	_, err := s.Cron(spec).Do(func() {
+		opts, err := NewImportOptions(app.Config())
+		if err != nil {
+			log.Printf("error al iniciar la importación programada: %v", err)
+			return
+		}
+
+		run, err := jobs.Start(ImportTriggerCron, opts)
+		switch {
+		case errors.Is(err, errImportRunning):
+			log.Println("importación programada omitida: otra instancia está importando")
+		case errors.Is(err, errImportsStopped):
+		case err != nil:
+			log.Printf("error al iniciar la importación programada: %v", err)
+		default:
+			log.Printf("importación programada %d iniciada", run.ID)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("import.schedule inválido %q: %w", spec, err)
+	}
+
+	s.StartAsync()
+	app.OnStop("contributors.importScheduler", func(ctx context.Context, _ *bunapp.App) error {
+		s.Stop()
+		return nil
+	})
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package contributors
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+// fixturePath es un extracto de DGII_RNC.TXT en Windows-1252 con fin de línea
+// CRLF, como lo publica la DGII. La última fila tiene un RNC con el dígito
+// verificador incorrecto.
+const fixturePath = "testdata/DGII_RNC.TXT"
+
+// fixtureRows son las filas que el COPY debe recibir del fixture.
+var fixtureRows = []string{
+	"1\t101850043\tPEÑA & ASOCIADOS SRL\tPEÑA\tSERVICIOS JURÍDICOS\tAV. JOHN F. KENNEDY\t12\tENSANCHE NACO\t8095550101\t2001-03-15\tACTIVO\tNORMAL",
+	"2\t131246796\tFERRETERÍA EL MARTILLO SRL\t\tVENTA DE ARTÍCULOS DE FERRETERÍA\tC/ DUARTE\t45\tCENTRO\t\t2015-07-01\tACTIVO\tRST",
+	"3\t00113918205\tJOSÉ NÚÑEZ\t\tACTIVIDADES PROFESIONALES\t\t\t\t\t\\N\tSUSPENDIDO\tNORMAL",
+	"4\t101850042\tREGISTRO CON DÍGITO ERRÓNEO SA\t\tCOMERCIO\t\t\t\t\t1990-01-02\tACTIVO\tNORMAL",
+}
+
+func TestParseContributorsFromSources(t *testing.T) {
+	raw, err := os.ReadFile(fixturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	xzPath := filepath.Join(dir, "DGII_RNC.TXT.xz")
+	writeXZ(t, xzPath, raw)
+	zipPath := filepath.Join(dir, "DGII_RNC.zip")
+	writeZIP(t, zipPath, map[string][]byte{dgiiRNCEntryName: raw})
+	zipXZPath := filepath.Join(dir, "DGII_RNC_XZ.zip")
+	writeZIP(t, zipXZPath, map[string][]byte{"RNC.TXT.xz": readFile(t, xzPath)})
+
+	tests := []struct {
+		name   string
+		source ContributorSource
+	}{
+		{"txt", &TextFileSource{Path: fixturePath}},
+		{"txt.xz", &TextFileSource{Path: xzPath}},
+		{"zip", &ZIPFileSource{Path: zipPath}},
+		{"zip with xz entry", &ZIPFileSource{Path: zipXZPath, Entry: "RNC.TXT.xz"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, result := parseFixture(t, test.source)
+			if got := strings.Split(strings.TrimSuffix(rows, "\n"), "\n"); !slices.Equal(got, fixtureRows) {
+				t.Errorf("COPY rows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(fixtureRows, "\n"))
+			}
+			if result.LinesRead != 4 || result.ParseErrors != 0 || result.EncodingErrors != 0 {
+				t.Errorf("result = %+v, want 4 lines without errors", result)
+			}
+			if result.CheckDigitWarnings != 1 {
+				t.Errorf("CheckDigitWarnings = %d, want 1", result.CheckDigitWarnings)
+			}
+			if result.Layout != "v1" {
+				t.Errorf("Layout = %q, want v1", result.Layout)
+			}
+		})
+	}
+}
+
+func TestTextFileSourceHash(t *testing.T) {
+	f, err := (&TextFileSource{Path: fixturePath}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	raw := readFile(t, fixturePath)
+	if f.Size != int64(len(raw)) || f.EntrySize != f.Size {
+		t.Errorf("Size = %d, EntrySize = %d, want %d", f.Size, f.EntrySize, len(raw))
+	}
+	if len(f.SHA256) != 64 {
+		t.Errorf("SHA256 = %q", f.SHA256)
+	}
+}
+
+func TestZIPFileSourceMissingEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "DGII_RNC.zip")
+	writeZIP(t, path, map[string][]byte{"OTRO.TXT": []byte("x")})
+
+	if _, err := (&ZIPFileSource{Path: path}).Open(context.Background()); err == nil {
+		t.Fatal("Open() = nil, want an error for the missing entry")
+	}
+}
+
+// parseFixture abre source y lo pasa por parseContributors como lo hace la
+// importación, devolviendo el texto que recibiría el COPY.
+func parseFixture(t *testing.T, source ContributorSource) (string, *ImportResult) {
+	t.Helper()
+	ctx := context.Background()
+
+	file, err := source.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder, err := newLineDecoder(EncodingAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := &stagingWriter{w: bufio.NewWriter(&buf)}
+	result := new(ImportResult)
+	h := new(ContributorHandler)
+	// Sin rechazos el recorder no usa la base de datos.
+	err = h.parseContributors(ctx, file, decoder, w, newRejectionRecorder(nil, 0),
+		ImportOptions{}, result, new(importProgress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), result
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func writeXZ(t *testing.T, path string, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZIP(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEntryLimitReader(t *testing.T) {
+	data := strings.Repeat("x", 10)
+
+	b, err := io.ReadAll(newEntryLimitReader(strings.NewReader(data), 10))
+	if err != nil || len(b) != 10 {
+		t.Errorf("ReadAll() = %d bytes, %v, want 10 bytes", len(b), err)
+	}
+
+	// Un archivo más grande que el límite falla en lugar de cortarse.
+	b, err = io.ReadAll(newEntryLimitReader(strings.NewReader(data), 9))
+	if !errors.Is(err, ErrEntryTooLarge) || len(b) != 9 {
+		t.Errorf("ReadAll() = %d bytes, %v, want 9 bytes and ErrEntryTooLarge", len(b), err)
+	}
+}
+
+func TestLineSplitterLongLine(t *testing.T) {
+	input := "a\r\n" + strings.Repeat("x", 20) + "\r\nb\r\n" + strings.Repeat("y", 30)
+	splitter := &lineSplitter{max: 8}
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 4), splitter.max+1)
+	scanner.Split(splitter.Split)
+
+	var got []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if splitter.truncated {
+			line += "…"
+		}
+		got = append(got, line)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	// Las líneas largas se cortan en max bytes y el resto se descarta.
+	want := []string{"a", "xxxxxxxx…", "b", "yyyyyyyy…"}
+	if !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
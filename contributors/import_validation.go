@@ -0,0 +1,85 @@
+package contributors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrImportValidation indica que el archivo cargado en la tabla temporal no
+// pasó la validación y que contributors no se modificó.
+var ErrImportValidation = errors.New("la importación no pasó la validación")
+
+// validateStaging revisa la tabla temporal antes de reemplazar el registro: no
+// puede haber RNC duplicados y la cantidad de filas no puede variar más de
+// opts.MaxRowDelta frente a la corrida anterior.
+func (h *ContributorHandler) validateStaging(
+	ctx context.Context, conn bun.IConn, opts ImportOptions,
+) error {
+	var total, distinct int
+	err := conn.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT count(*), count(DISTINCT rnc) FROM %s", contributorsStagingTable,
+	)).Scan(&total, &distinct)
+	if err != nil {
+		return err
+	}
+
+	if total == 0 {
+		return fmt.Errorf("%w: el archivo no tiene contribuyentes", ErrImportValidation)
+	}
+	if dups := total - distinct; dups > 0 {
+		return fmt.Errorf("%w: %d RNC duplicados", ErrImportValidation, dups)
+	}
+
+	prev, err := previousRegistrySize(ctx, h.app.DB())
+	if err != nil {
+		return err
+	}
+	if prev == 0 {
+		return nil
+	}
+
+	delta := math.Abs(float64(distinct-prev)) / float64(prev)
+	if delta > opts.MaxRowDelta {
+		return fmt.Errorf("%w: %d filas frente a %d de la corrida anterior (variación %.1f%%, máximo %.1f%%)",
+			ErrImportValidation, distinct, prev, delta*100, opts.MaxRowDelta*100)
+	}
+	return nil
+}
+
+// previousRegistrySize devuelve la cantidad de RNC de la última importación
+// exitosa en modo swap o, si no hay ninguna, la cantidad de contribuyentes
+// vigentes. Las corridas merge no cuentan porque pueden ser de archivos
+// parciales.
+func previousRegistrySize(ctx context.Context, db bun.IDB) (int, error) {
+	var n int
+	err := db.NewSelect().
+		Model((*ImportRun)(nil)).
+		ColumnExpr("inserted + updated + unchanged").
+		Where("status = ?", ImportStatusSucceeded).
+		Where("mode = ?", ImportModeSwap).
+		Where("NOT dry_run").
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx, &n)
+	if err == nil {
+		return n, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	count, err := db.NewSelect().
+		Model((*Contributor)(nil)).
+		Where("removed_from_registry_at IS NULL").
+		Where("deleted_at IS NULL").
+		Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -4,22 +4,73 @@ import (
 	"context"
 
 	"my-dgii-api/bunapp"
+	"my-dgii-api/httputil"
+
+	"github.com/uptrace/bunrouter"
 )
 
 func init() {
 	bunapp.OnStart("contributor.initRoutes", func(ctx context.Context, app *bunapp.App) error {
-		app.DB().RegisterModel((*Contributor)(nil))
+		app.DB().RegisterModel(
+			(*Contributor)(nil),
+			(*ImportRun)(nil),
+			(*ImportRejection)(nil),
+			(*ContributorVersion)(nil),
+			(*WebhookSubscription)(nil),
+			(*WebhookDelivery)(nil),
+			(*WebhookDeliveryAttempt)(nil),
+		)
 
 		contributorHandler := NewContributorHandler(app)
+		importJobs := NewImportJobs(app, contributorHandler)
+		importRunHandler := NewImportRunHandler(app, importJobs)
+		webhookHandler := NewWebhookHandler(app)
+
+		// Los comandos de la CLI comparten estos hooks; solo el servidor de la
+		// API programa importaciones.
+		if app.Config().Service == "api" {
+			if err := startImportScheduler(app, importJobs); err != nil {
+				return err
+			}
+			NewWebhookDispatcher(app).Start()
+		}
 
 		g := app.APIRouter().NewGroup("/v1")
 
+		g.GET("/contributors", contributorHandler.GetContributors)
+		g.GET("/contributors/changes", contributorHandler.GetChanges)
+		g.GET("/contributors/search", contributorHandler.Search)
+		g.GET("/contributors/suggest", contributorHandler.Suggest)
+		g.GET("/contributors/:rnc", contributorHandler.GetByRnc)
+		g.GET("/contributors/:rnc/history", contributorHandler.GetHistory)
+		g.POST("/contributors", contributorHandler.CreateContributor)
+		g.POST("/contributors/lookup", contributorHandler.Lookup)
+		g.PUT("/contributors/:rnc", contributorHandler.UpdateContributor)
+		g.PATCH("/contributors/:rnc", contributorHandler.UpdateContributor)
+		g.DELETE("/contributors/:rnc", contributorHandler.DeleteContributor)
+		g.POST("/contributors/:rnc/restore", contributorHandler.RestoreContributor)
+
+		g.GET("/validate/:id", contributorHandler.Validate)
+
+		g.POST("/contributors/import", importRunHandler.Start)
+
+		g.GET("/imports", importRunHandler.List)
+		g.GET("/imports/:id", importRunHandler.Get)
+		g.POST("/imports/:id/cancel", importRunHandler.Cancel)
+		g.GET("/imports/:id/rejections", importRunHandler.Rejections)
 
-        g.GET("/contributors", contributorHandler.GetContributors)
-        g.GET("/contributors/:rnc", contributorHandler.GetByRnc)
-        g.POST("/contributors", contributorHandler.CreateContributor)
+		g.WithGroup("/webhooks", func(g *bunrouter.Group) {
+			g = g.Use(httputil.BodyLimit(maxWebhookBodySize))
 
-		g.POST("/contributors/import", contributorHandler.ImportContributors)
+			g.POST("", webhookHandler.Create)
+			g.GET("", webhookHandler.List)
+			g.GET("/:id", webhookHandler.Get)
+			g.PUT("/:id", webhookHandler.Update)
+			g.DELETE("/:id", webhookHandler.Delete)
+			g.GET("/:id/deliveries", webhookHandler.Deliveries)
+			g.GET("/:id/deliveries/:delivery_id", webhookHandler.Delivery)
+			g.POST("/:id/deliveries/:delivery_id/retry", webhookHandler.RetryDelivery)
+		})
 
 		return nil
 	})
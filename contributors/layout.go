@@ -0,0 +1,138 @@
+package contributors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-dgii-api/rnc"
+)
+
+// Columnas publicadas en DGII_RNC.TXT.
+const (
+	colRNC              = "rnc"
+	colBusinessName     = "business_name"
+	colCommercialName   = "commercial_name"
+	colEconomicActivity = "economic_activity"
+	colStreet           = "street"
+	colStreetNumber     = "street_number"
+	colSector           = "sector"
+	colPhone            = "phone"
+	colStartDate        = "start_date_of_operations"
+	colState            = "state"
+	colPaymentRegime    = "payment_regime"
+)
+
+const dgiiDateLayout = "02/01/2006"
+
+// contributorLayout describe el orden de las columnas de una versión del
+// archivo de la DGII. Cuando la DGII cambie el formato se agrega una versión
+// nueva en lugar de modificar una existente.
+type contributorLayout struct {
+	Version string
+	Columns []string
+
+	index map[string]int
+}
+
+var contributorLayouts = []*contributorLayout{
+	newContributorLayout("v1",
+		colRNC, colBusinessName, colCommercialName, colEconomicActivity,
+		colStreet, colStreetNumber, colSector, colPhone,
+		colStartDate, colState, colPaymentRegime,
+	),
+	// Archivos anteriores a la publicación del régimen de pagos.
+	newContributorLayout("v0",
+		colRNC, colBusinessName, colCommercialName, colEconomicActivity,
+		colStreet, colStreetNumber, colSector, colPhone,
+		colStartDate, colState,
+	),
+}
+
+func newContributorLayout(version string, columns ...string) *contributorLayout {
+	l := &contributorLayout{
+		Version: version,
+		Columns: columns,
+		index:   make(map[string]int, len(columns)),
+	}
+	for i, col := range columns {
+		l.index[col] = i
+	}
+	return l
+}
+
+// detectContributorLayout elige la versión del formato a partir de la primera
+// línea del archivo. Un número de columnas desconocido se reporta como error
+// para no guardar datos corridos de columna.
+func detectContributorLayout(line string) (*contributorLayout, error) {
+	n := strings.Count(line, "|") + 1
+	for _, l := range contributorLayouts {
+		if len(l.Columns) == n {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("formato de DGII_RNC.TXT desconocido: %d columnas", n)
+}
+
+func (l *contributorLayout) field(fields []string, col string) string {
+	i, ok := l.index[col]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+// parseRegistryRNC normaliza un RNC o cédula como los guarda el padrón. La
+// DGII emitió algunos números cuyo dígito verificador no cumple el algoritmo,
+// así que esos se aceptan y checkDigitOK queda en false.
+func parseRegistryRNC(s string) (id rnc.ID, checkDigitOK bool, err error) {
+	id, err = rnc.Parse(s)
+	if errors.Is(err, rnc.ErrInvalidCheckDigit) {
+		return id, false, nil
+	}
+	return id, err == nil, err
+}
+
+// Parse convierte una línea del archivo en un Contributor. Los errores son
+// *lineError con el motivo del rechazo. Un RNC con el dígito verificador
+// incorrecto no rechaza la línea: se devuelve como advertencia en warning.
+func (l *contributorLayout) Parse(line string) (c *Contributor, warning *lineError, err error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != len(l.Columns) {
+		return nil, nil, newLineError(RejectionFieldCount,
+			"línea inválida (%d columnas, se esperaban %d)", len(fields), len(l.Columns))
+	}
+
+	raw := l.field(fields, colRNC)
+	id, checkDigitOK, err := parseRegistryRNC(raw)
+	if err != nil {
+		return nil, nil, newLineError(RejectionInvalidRNC, "RNC inválido: %q (%v)", raw, err)
+	}
+	if !checkDigitOK {
+		warning = newLineError(RejectionInvalidRNC,
+			"RNC %s con dígito verificador incorrecto", id)
+	}
+
+	var startDate time.Time
+	if s := l.field(fields, colStartDate); s != "" {
+		startDate, err = time.Parse(dgiiDateLayout, s)
+		if err != nil {
+			return nil, nil, newLineError(RejectionBadDate, "error al parsear la fecha %q", s)
+		}
+	}
+
+	return &Contributor{
+		RNC:                   id.String(),
+		BusinessName:          l.field(fields, colBusinessName),
+		CommercialName:        l.field(fields, colCommercialName),
+		EconomicActivity:      l.field(fields, colEconomicActivity),
+		Street:                l.field(fields, colStreet),
+		StreetNumber:          l.field(fields, colStreetNumber),
+		Sector:                l.field(fields, colSector),
+		Phone:                 l.field(fields, colPhone),
+		StartDateOfOperations: startDate,
+		State:                 l.field(fields, colState),
+		PaymentRegime:         PaymentRegime(l.field(fields, colPaymentRegime)),
+	}, warning, nil
+}
@@ -0,0 +1,45 @@
+package contributors
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContributorLayoutParse(t *testing.T) {
+	layout := contributorLayouts[0]
+	line := func(rnc string) string {
+		return rnc + "|EMPRESA SRL|EMPRESA|VENTA AL POR MENOR|CALLE 1|10|CENTRO|8095550000|01/02/2003|ACTIVO|NORMAL"
+	}
+
+	c, warning, err := layout.Parse(line("1-01-85004-3"))
+	if err != nil || warning != nil {
+		t.Fatalf("Parse() warning = %v, err = %v", warning, err)
+	}
+	if c.RNC != "101850043" || c.BusinessName != "EMPRESA SRL" || c.PaymentRegime != PaymentRegimeNormal {
+		t.Errorf("Parse() = %+v", c)
+	}
+
+	// La DGII publica algunos RNC cuyo dígito verificador no cumple el
+	// algoritmo: se importan con una advertencia.
+	c, warning, err = layout.Parse(line("101850042"))
+	if err != nil {
+		t.Fatalf("Parse() err = %v", err)
+	}
+	if warning == nil || c == nil || c.RNC != "101850042" {
+		t.Errorf("Parse() = %+v, warning = %v, want the row with a warning", c, warning)
+	}
+
+	for _, rnc := range []string{"1018A0043", "12345"} {
+		_, _, err = layout.Parse(line(rnc))
+		var lineErr *lineError
+		if !errors.As(err, &lineErr) || lineErr.Reason != RejectionInvalidRNC {
+			t.Errorf("Parse(%q) err = %v, want %s", rnc, err, RejectionInvalidRNC)
+		}
+	}
+
+	_, _, err = layout.Parse("101850043|EMPRESA SRL")
+	var lineErr *lineError
+	if !errors.As(err, &lineErr) || lineErr.Reason != RejectionFieldCount {
+		t.Errorf("Parse() err = %v, want %s", err, RejectionFieldCount)
+	}
+}
@@ -0,0 +1,85 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// maxLookupItems es el máximo de RNCs por solicitud JSON y el tamaño de cada
+// lote del modo NDJSON.
+const maxLookupItems = 1000
+
+// LookupStatus es el resultado de buscar un RNC del lote.
+type LookupStatus string
+
+const (
+	LookupFound         LookupStatus = "found"
+	LookupNotFound      LookupStatus = "not_found"
+	LookupInvalidFormat LookupStatus = "invalid_format"
+)
+
+// LookupResult es el resultado de un elemento del lote. RNC es el valor
+// normalizado que se buscó.
+type LookupResult struct {
+	Input       string       `json:"input"`
+	Status      LookupStatus `json:"status"`
+	RNC         string       `json:"rnc,omitempty"`
+	Contributor *Contributor `json:"contributor,omitempty"`
+}
+
+// LookupContributors busca todos los RNCs con una sola consulta y devuelve un
+// resultado por cada entrada, en el mismo orden.
+func LookupContributors(ctx context.Context, db bun.IDB, inputs []string) ([]*LookupResult, error) {
+	results := make([]*LookupResult, len(inputs))
+	var rncs []string
+	// badCheckDigit marca las entradas que solo se buscan porque el padrón
+	// tiene algunos RNC con el dígito verificador incorrecto.
+	badCheckDigit := make([]bool, len(inputs))
+	for i, input := range inputs {
+		id, checkDigitOK, err := parseRegistryRNC(input)
+		if err != nil {
+			results[i] = &LookupResult{Input: input, Status: LookupInvalidFormat}
+			continue
+		}
+		badCheckDigit[i] = !checkDigitOK
+		results[i] = &LookupResult{Input: input, Status: LookupNotFound, RNC: id.String()}
+		rncs = append(rncs, id.String())
+	}
+	if len(rncs) == 0 {
+		return results, nil
+	}
+
+	var contributors []*Contributor
+	err := db.NewSelect().
+		Model(&contributors).
+		Where("c.rnc IN (?)", bun.In(rncs)).
+		Where("c.deleted_at IS NULL").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up contributors: %w", err)
+	}
+
+	if err := resolveLastSeen(ctx, db, contributors...); err != nil {
+		return nil, err
+	}
+
+	byRNC := make(map[string]*Contributor, len(contributors))
+	for _, c := range contributors {
+		byRNC[c.RNC] = c
+	}
+	for i, r := range results {
+		if r.Status != LookupNotFound {
+			continue
+		}
+		if c, ok := byRNC[r.RNC]; ok {
+			r.Status = LookupFound
+			r.Contributor = c
+		} else if badCheckDigit[i] {
+			r.Status = LookupInvalidFormat
+			r.RNC = ""
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,92 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RejectionReason es el motivo por el que una línea del archivo no se importó.
+type RejectionReason string
+
+const (
+	RejectionFieldCount  RejectionReason = "invalid_field_count"
+	RejectionBadDate     RejectionReason = "bad_date"
+	RejectionInvalidRNC  RejectionReason = "invalid_rnc"
+	RejectionEncoding    RejectionReason = "encoding"
+	RejectionLineTooLong RejectionReason = "line_too_long"
+)
+
+// ImportRejection es una línea rechazada por una importación. Se guarda tal
+// como se leyó, ya convertida a UTF-8, para poder revisarla después.
+type ImportRejection struct {
+	bun.BaseModel `bun:"table:import_rejections,alias:rej"`
+
+	ID          int64           `json:"id" bun:",pk,autoincrement"`
+	ImportRunID int64           `json:"import_run_id" bun:",notnull"`
+	LineNumber  int             `json:"line_number" bun:",notnull"`
+	Reason      RejectionReason `json:"reason" bun:",notnull"`
+	Message     string          `json:"message"`
+	Raw         string          `json:"raw" bun:",notnull"`
+	CreatedAt   time.Time       `json:"created_at" bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// lineError es el error que devuelve el parseo de una línea.
+type lineError struct {
+	Reason  RejectionReason
+	Message string
+}
+
+func newLineError(reason RejectionReason, format string, args ...any) *lineError {
+	return &lineError{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *lineError) Error() string {
+	return e.Message
+}
+
+//------------------------------------------------------------------------------
+
+// rejectionBatchSize es la cantidad de rechazos que se insertan juntos.
+const rejectionBatchSize = 500
+
+// rejectionRecorder guarda los rechazos de una corrida en lotes para no hacer
+// una inserción por línea.
+type rejectionRecorder struct {
+	db    bun.IDB
+	runID int64
+	batch []*ImportRejection
+}
+
+func newRejectionRecorder(db bun.IDB, runID int64) *rejectionRecorder {
+	return &rejectionRecorder{db: db, runID: runID}
+}
+
+func (r *rejectionRecorder) Add(
+	ctx context.Context, lineNumber int, raw string, err *lineError,
+) error {
+	r.batch = append(r.batch, &ImportRejection{
+		ImportRunID: r.runID,
+		LineNumber:  lineNumber,
+		Reason:      err.Reason,
+		Message:     err.Message,
+		Raw:         raw,
+	})
+	if len(r.batch) < rejectionBatchSize {
+		return nil
+	}
+	return r.Flush(ctx)
+}
+
+func (r *rejectionRecorder) Flush(ctx context.Context) error {
+	if len(r.batch) == 0 {
+		return nil
+	}
+	if _, err := r.db.NewInsert().Model(&r.batch).Exec(ctx); err != nil {
+		return fmt.Errorf("error al guardar las líneas rechazadas: %w", err)
+	}
+	r.batch = r.batch[:0]
+	return nil
+}
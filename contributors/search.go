@@ -0,0 +1,65 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// defaultSearchMinScore es el umbral de similitud por defecto. Es más
+	// bajo que el de pg_trgm (0.6) para tolerar nombres mal escritos.
+	defaultSearchMinScore = 0.3
+)
+
+// ContributorSearchResult es un contribuyente encontrado por la búsqueda con
+// su puntuación entre 0 y 1.
+type ContributorSearchResult struct {
+	Contributor `bun:",extend"`
+
+	Score float64 `json:"score" bun:",scanonly"`
+}
+
+// SearchContributors busca por similitud de trigramas sobre la razón social y
+// el nombre comercial, sin distinguir mayúsculas ni acentos. La puntuación es
+// la mayor word_similarity de los dos nombres, así que una palabra suelta
+// encuentra nombres largos que la contienen.
+func SearchContributors(
+	ctx context.Context, db *bun.DB, q string, minScore float64, limit int,
+) ([]*ContributorSearchResult, error) {
+	var results []*ContributorSearchResult
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// El operador <% usa este umbral y es el que aprovecha los índices GIN.
+		_, err := tx.ExecContext(ctx,
+			"SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)",
+			strconv.FormatFloat(minScore, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+
+		return tx.NewSelect().
+			Model(&results).
+			ColumnExpr("c.*").
+			ColumnExpr(`greatest(
+				word_similarity(f_unaccent(lower(?0)), f_unaccent(lower(c.business_name))),
+				word_similarity(f_unaccent(lower(?0)), f_unaccent(lower(c.commercial_name)))
+			) AS score`, q).
+			WhereGroup(" AND ", func(sel *bun.SelectQuery) *bun.SelectQuery {
+				return sel.
+					Where("f_unaccent(lower(?)) <% f_unaccent(lower(c.business_name))", q).
+					WhereOr("f_unaccent(lower(?)) <% f_unaccent(lower(c.commercial_name))", q)
+			}).
+			Where("c.deleted_at IS NULL").
+			OrderExpr("score DESC, c.business_name ASC, c.rnc ASC").
+			Limit(limit).
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching contributors: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,446 @@
+package contributors
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"my-dgii-api/bunapp"
+
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	dgiiRNCURL       = "https://dgii.gov.do/app/WebApps/Consultas/RNC/DGII_RNC.zip"
+	dgiiRNCEntryName = "TMP/DGII_RNC.TXT"
+
+	// maxDownloadSize limita el tamaño del ZIP descargado. El archivo de la
+	// DGII pesa alrededor de 40MB.
+	maxDownloadSize = 256 << 20
+	// maxEntrySize limita el tamaño descomprimido del TXT para que un ZIP
+	// malformado no llene el disco ni deje la importación corriendo sin fin.
+	maxEntrySize = 2 << 30
+)
+
+// Tipos de fuente que se pueden configurar en import.source.type.
+const (
+	SourceURL   = "url"
+	SourceZIP   = "zip"
+	SourceTXT   = "txt"
+	SourceStdin = "stdin"
+)
+
+// ErrNotModified indica que el archivo no cambió desde la última importación.
+var ErrNotModified = errors.New("el archivo de la DGII no cambió desde la última importación")
+
+// ContributorSource abre el archivo de contribuyentes de la DGII desde algún
+// origen: la web de la DGII, un archivo local o la entrada estándar.
+type ContributorSource interface {
+	Open(ctx context.Context) (*ContributorFile, error)
+	String() string
+}
+
+// NewContributorSource crea la fuente descrita por la configuración. Sin tipo
+// se usa la URL pública de la DGII.
+func NewContributorSource(cfg bunapp.ImportSourceConfig) (ContributorSource, error) {
+	switch cfg.Type {
+	case "", SourceURL:
+		src := &URLSource{URL: cfg.URL, Entry: cfg.Entry}
+		if src.URL == "" {
+			src.URL = dgiiRNCURL
+		}
+		return src, nil
+	case SourceZIP:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("la fuente %q requiere path", cfg.Type)
+		}
+		return &ZIPFileSource{Path: cfg.Path, Entry: cfg.Entry}, nil
+	case SourceTXT:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("la fuente %q requiere path", cfg.Type)
+		}
+		return &TextFileSource{Path: cfg.Path}, nil
+	case SourceStdin:
+		return &StdinSource{Entry: cfg.Entry}, nil
+	default:
+		return nil, fmt.Errorf("tipo de fuente desconocido: %q", cfg.Type)
+	}
+}
+
+// URLSource descarga el ZIP de la DGII a un archivo temporal.
+type URLSource struct {
+	URL string
+	// Entry es el nombre del TXT dentro del ZIP. Por defecto TMP/DGII_RNC.TXT.
+	Entry string
+
+	// ETag y LastModified son los de la última descarga importada. Si se
+	// indican, la descarga es condicional y Open devuelve ErrNotModified
+	// cuando el servidor responde 304.
+	ETag         string
+	LastModified string
+}
+
+func (s *URLSource) String() string {
+	return SourceURL + ":" + s.URL
+}
+
+// Open descarga el ZIP calculando el SHA-256 mientras se escribe y abre la
+// entrada del TXT desde el disco. Así el uso de memoria no depende del tamaño
+// del archivo.
+func (s *URLSource) Open(ctx context.Context) (*ContributorFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.ETag != "" {
+		req.Header.Set("If-None-Match", s.ETag)
+	}
+	if s.LastModified != "" {
+		req.Header.Set("If-Modified-Since", s.LastModified)
+	}
+
+	f, err := downloadToTempFile(req, maxDownloadSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.openZIPEntry(s.Entry); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// ZIPFileSource lee un ZIP de la DGII copiado a mano al servidor.
+type ZIPFileSource struct {
+	Path  string
+	Entry string
+}
+
+func (s *ZIPFileSource) String() string {
+	return SourceZIP + ":" + s.Path
+}
+
+func (s *ZIPFileSource) Open(ctx context.Context) (*ContributorFile, error) {
+	f, err := openLocalFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.openZIPEntry(s.Entry); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// TextFileSource lee el TXT de la DGII, plano o comprimido con xz.
+type TextFileSource struct {
+	Path string
+}
+
+func (s *TextFileSource) String() string {
+	return SourceTXT + ":" + s.Path
+}
+
+func (s *TextFileSource) Open(ctx context.Context) (*ContributorFile, error) {
+	f, err := openLocalFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.openText(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// StdinSource lee el archivo desde la entrada estándar. Acepta un ZIP, un TXT
+// comprimido con xz o un TXT plano.
+type StdinSource struct {
+	Entry string
+}
+
+func (s *StdinSource) String() string {
+	return SourceStdin
+}
+
+// Open copia la entrada estándar a un archivo temporal para conocer su tamaño
+// y su SHA-256 antes de empezar a importar.
+func (s *StdinSource) Open(ctx context.Context) (*ContributorFile, error) {
+	file, size, sum, err := copyToTempFile(os.Stdin, maxDownloadSize)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la entrada estándar: %w", err)
+	}
+
+	f := &ContributorFile{
+		Size:   size,
+		SHA256: sum,
+		file:   file,
+		temp:   true,
+	}
+
+	isZIP, err := f.hasPrefix(zipMagic)
+	if err == nil {
+		if isZIP {
+			err = f.openZIPEntry(s.Entry)
+		} else {
+			err = f.openText()
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	xzMagic  = []byte("\xfd7zXZ\x00")
+)
+
+// ContributorFile es el TXT de contribuyentes listo para leer. Close cierra el
+// archivo y, si era temporal, lo elimina.
+type ContributorFile struct {
+	io.Reader
+
+	// Size y SHA256 describen el archivo tal como vino de la fuente.
+	Size   int64
+	SHA256 string
+	// EntrySize es el tamaño descomprimido del TXT, o 0 si no se conoce.
+	EntrySize int64
+	// ETag y LastModified son los encabezados de la respuesta cuando el
+	// archivo se descargó.
+	ETag         string
+	LastModified string
+
+	entry io.Closer
+	file  *os.File
+	temp  bool
+}
+
+func (f *ContributorFile) Close() error {
+	var firstErr error
+	if f.entry != nil {
+		firstErr = f.entry.Close()
+	}
+	if err := f.file.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if f.temp {
+		if err := os.Remove(f.file.Name()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f *ContributorFile) openZIPEntry(name string) error {
+	if name == "" {
+		name = dgiiRNCEntryName
+	}
+
+	zipReader, err := zip.NewReader(f.file, f.Size)
+	if err != nil {
+		return fmt.Errorf("error al leer el ZIP: %w", err)
+	}
+
+	var txtFile *zip.File
+	for _, file := range zipReader.File {
+		if file.Name == name {
+			txtFile = file
+			break
+		}
+	}
+	if txtFile == nil {
+		return fmt.Errorf("no se encontró el archivo %s", name)
+	}
+	if txtFile.UncompressedSize64 > maxEntrySize {
+		return fmt.Errorf("el archivo %s excede el tamaño máximo (%d bytes)", name, maxEntrySize)
+	}
+
+	rc, err := txtFile.Open()
+	if err != nil {
+		return fmt.Errorf("error al abrir el archivo TXT: %w", err)
+	}
+	f.entry = rc
+	f.EntrySize = int64(txtFile.UncompressedSize64)
+
+	var reader io.Reader = rc
+	if strings.HasSuffix(txtFile.Name, ".xz") {
+		xzReader, err := xz.NewReader(rc)
+		if err != nil {
+			return fmt.Errorf("error al descomprimir el archivo: %w", err)
+		}
+		reader = xzReader
+		// El tamaño de la entrada es el comprimido.
+		f.EntrySize = 0
+	}
+	f.Reader = newEntryLimitReader(reader, maxEntrySize)
+
+	return nil
+}
+
+// openText lee el archivo como TXT, descomprimiéndolo si es xz.
+func (f *ContributorFile) openText() error {
+	isXZ, err := f.hasPrefix(xzMagic)
+	if err != nil {
+		return err
+	}
+
+	if !isXZ {
+		f.EntrySize = f.Size
+		f.Reader = bufio.NewReader(f.file)
+		return nil
+	}
+
+	xzReader, err := xz.NewReader(bufio.NewReader(f.file))
+	if err != nil {
+		return fmt.Errorf("error al descomprimir el archivo: %w", err)
+	}
+	f.Reader = newEntryLimitReader(xzReader, maxEntrySize)
+	return nil
+}
+
+// ErrEntryTooLarge indica que el TXT descomprimido excede maxEntrySize.
+var ErrEntryTooLarge = errors.New("el archivo descomprimido excede el tamaño máximo")
+
+// entryLimitReader lee hasta n bytes de r y devuelve ErrEntryTooLarge si r
+// tiene más. A diferencia de io.LimitReader, un archivo demasiado grande no
+// termina en un EOF normal que se importaría como un archivo parcial.
+type entryLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func newEntryLimitReader(r io.Reader, limit int64) *entryLimitReader {
+	return &entryLimitReader{r: r, n: limit, limit: limit}
+}
+
+func (l *entryLimitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Se llegó al límite: solo es válido si r también terminó.
+		var b [1]byte
+		if n, err := io.ReadFull(l.r, b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w (%d bytes)", ErrEntryTooLarge, l.limit)
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// hasPrefix indica si el archivo empieza con magic y lo deja posicionado al
+// inicio.
+func (f *ContributorFile) hasPrefix(magic []byte) (bool, error) {
+	buf := make([]byte, len(magic))
+	n, err := io.ReadFull(f.file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return bytes.Equal(buf[:n], magic), nil
+}
+
+// openLocalFile abre un archivo local y calcula su SHA-256.
+func openLocalFile(path string) (*ContributorFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el archivo: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error al leer el archivo: %w", err)
+	}
+
+	return &ContributorFile{
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		file:   file,
+	}, nil
+}
+
+// downloadToTempFile guarda el cuerpo de la respuesta en un archivo temporal
+// posicionado al inicio. Devuelve ErrNotModified si el servidor responde 304.
+func downloadToTempFile(req *http.Request, maxBytes int64) (*ContributorFile, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error al descargar el ZIP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, ErrNotModified
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("error al descargar el ZIP: %s", resp.Status)
+	case resp.ContentLength > maxBytes:
+		return nil, fmt.Errorf("el ZIP excede el tamaño máximo (%d bytes)", maxBytes)
+	}
+
+	file, size, sum, err := copyToTempFile(resp.Body, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el cuerpo de la respuesta: %w", err)
+	}
+	return &ContributorFile{
+		Size:         size,
+		SHA256:       sum,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		file:         file,
+		temp:         true,
+	}, nil
+}
+
+// copyToTempFile copia r a un archivo temporal calculando su SHA-256 y lo
+// devuelve posicionado al inicio.
+func copyToTempFile(r io.Reader, maxBytes int64) (_ *os.File, _ int64, _ string, err error) {
+	file, err := os.CreateTemp("", "dgii-rnc-*")
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("error al crear el archivo temporal: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if size > maxBytes {
+		return nil, 0, "", fmt.Errorf("el archivo excede el tamaño máximo (%d bytes)", maxBytes)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, "", err
+	}
+
+	return file, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
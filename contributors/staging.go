@@ -0,0 +1,248 @@
+package contributors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// contributorsStagingTable es una tabla temporal de la sesión donde se carga el
+// archivo con COPY antes de mezclarlo con contributors.
+const contributorsStagingTable = "contributors_staging"
+
+// stagingColumns es el orden de las columnas en el COPY.
+var stagingColumns = append([]string{"line_number", colRNC}, contributorDataColumns...)
+
+// createStagingTable crea la tabla temporal con los mismos tipos que
+// contributors pero sin restricciones, para que el COPY no falle por
+// duplicados.
+func createStagingTable(ctx context.Context, conn bun.IConn) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		DROP TABLE IF EXISTS %[1]s;
+		CREATE TEMP TABLE %[1]s AS
+			SELECT 0::bigint AS line_number, rnc, %[2]s
+			FROM contributors
+			WITH NO DATA;
+	`, contributorsStagingTable, strings.Join(contributorDataColumns, ", ")))
+	if err != nil {
+		return fmt.Errorf("error al crear la tabla %s: %w", contributorsStagingTable, err)
+	}
+	return nil
+}
+
+func dropStagingTable(ctx context.Context, conn bun.IConn) error {
+	_, err := conn.ExecContext(ctx, "DROP TABLE IF EXISTS "+contributorsStagingTable)
+	return err
+}
+
+// copyStagingRows carga en la tabla temporal las filas que escribe fill. fill
+// corre en su propio goroutine y escribe en formato de texto de COPY. Si fill
+// falla, el COPY se completa igual para no dejar la conexión a medio protocolo
+// y se devuelve el error de fill.
+func copyStagingRows(
+	ctx context.Context, conn bun.Conn, fill func(w *stagingWriter) error,
+) (int64, error) {
+	pr, pw := io.Pipe()
+
+	fillErr := make(chan error, 1)
+	go func() {
+		w := &stagingWriter{w: bufio.NewWriterSize(pw, 64<<10)}
+		err := fill(w)
+		if err == nil {
+			err = w.w.Flush()
+		}
+		fillErr <- err
+		_ = pw.Close()
+	}()
+
+	res, copyErr := pgdriver.CopyFrom(ctx, conn, pr,
+		fmt.Sprintf("COPY %s (%s) FROM STDIN",
+			contributorsStagingTable, strings.Join(stagingColumns, ", ")))
+	// Desbloquea a fill si el COPY terminó antes de leerlo todo.
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+
+	err := <-fillErr
+	if copyErr != nil {
+		return 0, fmt.Errorf("error al copiar a %s: %w", contributorsStagingTable, copyErr)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// stagingWriter escribe filas de contributors en formato de texto de COPY.
+type stagingWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (w *stagingWriter) Write(lineNumber int, c *Contributor) error {
+	b := w.buf[:0]
+	b = strconv.AppendInt(b, int64(lineNumber), 10)
+	b = append(b, '\t')
+	b = appendCopyText(b, c.RNC)
+	for _, col := range contributorDataColumns {
+		b = append(b, '\t')
+		switch col {
+		case colBusinessName:
+			b = appendCopyText(b, c.BusinessName)
+		case colCommercialName:
+			b = appendCopyText(b, c.CommercialName)
+		case colEconomicActivity:
+			b = appendCopyText(b, c.EconomicActivity)
+		case colStreet:
+			b = appendCopyText(b, c.Street)
+		case colStreetNumber:
+			b = appendCopyText(b, c.StreetNumber)
+		case colSector:
+			b = appendCopyText(b, c.Sector)
+		case colPhone:
+			b = appendCopyText(b, c.Phone)
+		case colStartDate:
+			b = appendCopyDate(b, c.StartDateOfOperations)
+		case colState:
+			b = appendCopyText(b, c.State)
+		case colPaymentRegime:
+			b = appendCopyText(b, string(c.PaymentRegime))
+		default:
+			return fmt.Errorf("columna sin valor para COPY: %s", col)
+		}
+	}
+	b = append(b, '\n')
+	w.buf = b
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+func appendCopyText(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case 0:
+			// PostgreSQL no acepta NUL en columnas de texto.
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func appendCopyDate(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return append(b, '\\', 'N')
+	}
+	return t.AppendFormat(b, "2006-01-02")
+}
+
+//------------------------------------------------------------------------------
+
+// mergeResult cuenta lo que hizo mergeStaging.
+type mergeResult struct {
+	Distinct int
+	Inserted int
+	Updated  int
+}
+
+// mergeStaging mezcla la tabla temporal con contributors en una sola sentencia.
+// Si un RNC aparece más de una vez en el archivo gana la última línea. Los
+// registros existentes conservan su ID y CreatedAt y solo se escriben cuando
+// alguna columna de datos cambió o el RNC vuelve a aparecer después de haber
+// sido retirado; así una importación semanal no reescribe todo el registro.
+// Esas filas quedan marcadas como vistas en la corrida runID; para las demás
+// resolveLastSeen deduce la última importación que las incluyó.
+func mergeStaging(ctx context.Context, db bun.IDB, runID int64) (*mergeResult, error) {
+	cols := strings.Join(contributorDataColumns, ", ")
+
+	set := make([]string, len(contributorDataColumns))
+	for i, col := range contributorDataColumns {
+		set[i] = col + " = EXCLUDED." + col
+	}
+
+	// Con el WHERE del DO UPDATE, RETURNING solo devuelve las filas insertadas
+	// y las que cambiaron.
+	query := fmt.Sprintf(`
+		WITH source AS (%[1]s), merged AS (
+			INSERT INTO contributors AS c (
+				id, rnc, %[2]s, created_at, updated_at, last_seen_import_id, last_seen_at
+			)
+			SELECT
+				gen_random_uuid()::varchar, rnc, %[2]s,
+				current_timestamp, current_timestamp, %[5]d, current_timestamp
+			FROM source
+			ON CONFLICT (rnc) DO UPDATE
+			SET %[3]s,
+				updated_at = current_timestamp,
+				version = c.version + 1,
+				last_seen_import_id = EXCLUDED.last_seen_import_id,
+				last_seen_at = EXCLUDED.last_seen_at,
+				removed_from_registry_at = NULL
+			WHERE %[4]s OR c.removed_from_registry_at IS NOT NULL
+			RETURNING xmax = 0 AS inserted
+		)
+		SELECT
+			(SELECT count(*) FROM source),
+			count(*) FILTER (WHERE inserted),
+			count(*) FILTER (WHERE NOT inserted)
+		FROM merged
+	`, stagingSourceQuery(), cols, strings.Join(set, ", "), rowChangedExpr("c", "EXCLUDED"), runID)
+
+	res := new(mergeResult)
+	if err := db.QueryRowContext(ctx, query).Scan(&res.Distinct, &res.Inserted, &res.Updated); err != nil {
+		return nil, fmt.Errorf("error al mezclar %s: %w", contributorsStagingTable, err)
+	}
+	return res, nil
+}
+
+// indexStagingTable indexa la tabla temporal por RNC para la mezcla y la
+// validación.
+func indexStagingTable(ctx context.Context, conn bun.IConn) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		CREATE INDEX ON %[1]s (rnc);
+		ANALYZE %[1]s;
+	`, contributorsStagingTable))
+	if err != nil {
+		return fmt.Errorf("error al indexar la tabla %s: %w", contributorsStagingTable, err)
+	}
+	return nil
+}
+
+// markMissingFromStaging marca como retirados del registro los RNC que no
+// están en la tabla temporal. Las filas no se eliminan para que se pueda
+// distinguir un RNC suspendido de uno que la DGII dejó de publicar.
+func markMissingFromStaging(ctx context.Context, db bun.IDB) (int, error) {
+	res, err := db.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE contributors c
+		SET removed_from_registry_at = current_timestamp, version = c.version + 1
+		WHERE c.removed_from_registry_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.rnc = c.rnc)
+	`, contributorsStagingTable))
+	if err != nil {
+		return 0, fmt.Errorf("error al marcar contribuyentes retirados: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -0,0 +1,79 @@
+package contributors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 25
+	// minSuggestNameLength evita que una sola letra recorra medio registro.
+	minSuggestNameLength = 2
+)
+
+// ContributorSuggestion es el resultado compacto del autocompletado.
+type ContributorSuggestion struct {
+	RNC          string `json:"rnc"`
+	BusinessName string `json:"business_name"`
+	State        string `json:"state"`
+}
+
+// rncPrefix devuelve los dígitos de q si q parece el inicio de un RNC o una
+// cédula, es decir, si solo tiene dígitos, guiones y espacios.
+func rncPrefix(q string) (string, bool) {
+	var b strings.Builder
+	for _, r := range q {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' || r == ' ':
+		default:
+			return "", false
+		}
+	}
+	return b.String(), b.Len() > 0
+}
+
+// SuggestContributors devuelve los contribuyentes cuyo RNC o razón social
+// empieza por q. Las dos búsquedas recorren un índice de prefijos en el mismo
+// orden del ORDER BY, así que cuestan lo mismo con cualquier tamaño de
+// registro. Se omiten los contribuyentes borrados y los que ya no están en el
+// registro.
+func SuggestContributors(
+	ctx context.Context, db bun.IDB, q string, limit int,
+) ([]ContributorSuggestion, error) {
+	suggestions := make([]ContributorSuggestion, 0, limit)
+	sel := db.NewSelect().
+		Model((*Contributor)(nil)).
+		Column("c.rnc", "c.business_name", "c.state").
+		Where("c.removed_from_registry_at IS NULL").
+		Where("c.deleted_at IS NULL").
+		Limit(limit)
+
+	if digits, ok := rncPrefix(q); ok {
+		sel = sel.
+			Where(`(c.rnc COLLATE "C") LIKE ?`, digits+"%").
+			OrderExpr(`(c.rnc COLLATE "C") ASC`)
+	} else {
+		sel = sel.
+			Where(`(f_unaccent(lower(c.business_name)) COLLATE "C") LIKE f_unaccent(lower(?)) || '%'`,
+				escapeLike(q)).
+			OrderExpr(`(f_unaccent(lower(c.business_name)) COLLATE "C") ASC, c.rnc ASC`)
+	}
+
+	if err := sel.Scan(ctx, &suggestions); err != nil {
+		return nil, fmt.Errorf("error selecting contributor suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapa los comodines de LIKE para buscar s literalmente.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,155 @@
+package contributors
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WebhookSubscription es una suscripción a los cambios de contribuyentes. Los
+// filtros vacíos no filtran; los que tienen valores deben cumplirse todos.
+type WebhookSubscription struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions,alias:ws"`
+
+	ID     int64  `json:"id" bun:",pk,autoincrement"`
+	URL    string `json:"url" bun:",notnull"`
+	Secret string `json:"secret,omitempty" bun:",notnull"`
+	Active bool   `json:"active" bun:",notnull,default:true"`
+
+	RNCs               []string     `json:"rncs" bun:"rncs,array"`
+	ChangeTypes        []ChangeType `json:"change_types" bun:",array"`
+	FromStates         []string     `json:"from_states" bun:",array"`
+	ToStates           []string     `json:"to_states" bun:",array"`
+	EconomicActivities []string     `json:"economic_activities" bun:",array"`
+
+	CreatedAt time.Time `json:"created_at" bun:",nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `json:"updated_at" bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Matches indica si el cambio le interesa a la suscripción. Con FromStates o
+// ToStates solo interesan los cambios de estado.
+func (s *WebhookSubscription) Matches(change *webhookChange) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.RNCs) > 0 && !slices.Contains(s.RNCs, change.RNC) {
+		return false
+	}
+	if len(s.ChangeTypes) > 0 && !slices.Contains(s.ChangeTypes, change.ChangeType) {
+		return false
+	}
+	if len(s.EconomicActivities) > 0 && !slices.Contains(s.EconomicActivities, change.EconomicActivity) {
+		return false
+	}
+	if len(s.FromStates) > 0 || len(s.ToStates) > 0 {
+		if change.PreviousState == change.State {
+			return false
+		}
+		if len(s.FromStates) > 0 && !slices.Contains(s.FromStates, change.PreviousState) {
+			return false
+		}
+		if len(s.ToStates) > 0 && !slices.Contains(s.ToStates, change.State) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookSecret genera un secreto aleatorio para firmar las entregas.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// signWebhook firma timestamp.body con HMAC-SHA256. El receptor debe
+// recalcular la firma con su secreto y rechazar timestamps viejos.
+func signWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//------------------------------------------------------------------------------
+
+// WebhookDeliveryStatus es el estado de una entrega.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryDead indica que se agotaron los reintentos. La entrega
+	// se puede reintentar a mano.
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery es un evento pendiente o entregado a una suscripción.
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries,alias:wd"`
+
+	ID             int64                 `json:"id" bun:",pk,autoincrement"`
+	SubscriptionID int64                 `json:"subscription_id" bun:",notnull"`
+	EventType      string                `json:"event_type" bun:",notnull"`
+	Payload        json.RawMessage       `json:"payload" bun:"type:jsonb,notnull"`
+	Status         WebhookDeliveryStatus `json:"status" bun:",notnull"`
+	Attempts       int                   `json:"attempts" bun:",notnull,default:0"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" bun:",notnull"`
+	LastStatusCode int                   `json:"last_status_code,omitempty" bun:",nullzero"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at" bun:",nullzero,notnull,default:current_timestamp"`
+	DeliveredAt    bun.NullTime          `json:"delivered_at"`
+
+	AttemptLog []*WebhookDeliveryAttempt `json:"attempt_log,omitempty" bun:"rel:has-many,join:id=delivery_id"`
+}
+
+// WebhookDeliveryAttempt es una entrada del registro de intentos de entrega.
+type WebhookDeliveryAttempt struct {
+	bun.BaseModel `bun:"table:webhook_delivery_attempts,alias:wda"`
+
+	ID          int64     `json:"id" bun:",pk,autoincrement"`
+	DeliveryID  int64     `json:"delivery_id" bun:",notnull"`
+	AttemptedAt time.Time `json:"attempted_at" bun:",notnull"`
+	StatusCode  int       `json:"status_code,omitempty" bun:",nullzero"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// webhookFeedState es la posición del despachador en el feed de cambios. La
+// tabla tiene una sola fila.
+type webhookFeedState struct {
+	bun.BaseModel `bun:"table:webhook_feed_state,alias:wfs"`
+
+	ID        int    `bun:",pk"`
+	TxID      uint64 `bun:"txid,type:xid8,notnull"`
+	VersionID int64  `bun:",notnull"`
+}
+
+// webhookChange es una versión del feed de cambios con el estado anterior del
+// contribuyente.
+type webhookChange struct {
+	ContributorVersion `bun:",extend"`
+
+	PreviousState string `json:"previous_state" bun:",scanonly"`
+}
+
+// webhookEvent es el cuerpo que recibe el suscriptor.
+type webhookEvent struct {
+	Type      string         `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      *webhookChange `json:"data"`
+}
+
+func webhookEventType(t ChangeType) string {
+	return "contributor." + string(t)
+}
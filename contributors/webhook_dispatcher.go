@@ -0,0 +1,380 @@
+package contributors
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"my-dgii-api/bunapp"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultWebhookMaxAttempts = 10
+	webhookPollInterval       = 5 * time.Second
+	webhookFeedBatchSize      = 500
+	webhookDeliveryBatchSize  = 50
+	webhookDeliveryTimeout    = 10 * time.Second
+	// webhookDeliveryLease aparta una entrega mientras se envía para que otra
+	// instancia no la tome al mismo tiempo.
+	webhookDeliveryLease = 2 * time.Minute
+	webhookBackoffBase   = 30 * time.Second
+	webhookBackoffMax    = 6 * time.Hour
+)
+
+// WebhookDispatcher convierte el feed de cambios en entregas para las
+// suscripciones y las envía con reintentos. Varias instancias pueden correr a
+// la vez: el avance en el feed se serializa con un lock de fila y las entregas
+// se reparten con FOR UPDATE SKIP LOCKED.
+type WebhookDispatcher struct {
+	app         *bunapp.App
+	client      *http.Client
+	maxAttempts int
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWebhookDispatcher crea el despachador y lo detiene junto con la
+// aplicación.
+func NewWebhookDispatcher(app *bunapp.App) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		app:         app,
+		client:      newWebhookClient(webhookDeliveryTimeout),
+		maxAttempts: app.Config().Webhooks.MaxAttempts,
+		stop:        make(chan struct{}),
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultWebhookMaxAttempts
+	}
+	app.OnStop("contributors.webhookDispatcher", func(ctx context.Context, _ *bunapp.App) error {
+		return d.Stop(ctx)
+	})
+	return d
+}
+
+// Start corre el despachador en segundo plano hasta que se llame Stop.
+func (d *WebhookDispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(d.app.Context()))
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.dispatchChanges(ctx); err != nil {
+				log.Printf("error al generar eventos de webhooks: %v", err)
+			}
+			if err := d.deliverDue(ctx); err != nil {
+				log.Printf("error al entregar webhooks: %v", err)
+			}
+
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	go func() {
+		<-d.stop
+		cancel()
+	}()
+}
+
+// Stop detiene el despachador y espera a que termine el ciclo en curso.
+func (d *WebhookDispatcher) Stop(ctx context.Context) error {
+	close(d.stop)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatchChanges lee el feed de cambios desde la última posición y crea las
+// entregas de las suscripciones que coinciden, en la misma transacción en la
+// que avanza la posición.
+func (d *WebhookDispatcher) dispatchChanges(ctx context.Context) error {
+	for {
+		n, err := d.dispatchBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < webhookFeedBatchSize {
+			return nil
+		}
+	}
+}
+
+func (d *WebhookDispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	var n int
+	err := d.app.DB().RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		state := new(webhookFeedState)
+		err := tx.NewSelect().
+			Model(state).
+			Column("txid", "version_id").
+			Where("id = 1").
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return fmt.Errorf("error selecting webhook feed state: %w", err)
+		}
+
+		changes, err := selectWebhookChanges(ctx, tx, ChangeCursor{TxID: state.TxID, ID: state.VersionID})
+		if err != nil {
+			return err
+		}
+		n = len(changes)
+		if n == 0 {
+			return nil
+		}
+
+		var subs []*WebhookSubscription
+		if err := tx.NewSelect().Model(&subs).Where("active").Scan(ctx); err != nil {
+			return fmt.Errorf("error selecting webhook subscriptions: %w", err)
+		}
+
+		var deliveries []*WebhookDelivery
+		now := time.Now().UTC()
+		for _, change := range changes {
+			for _, sub := range subs {
+				if !sub.Matches(change) {
+					continue
+				}
+				delivery, err := newWebhookDelivery(sub, change, now)
+				if err != nil {
+					return err
+				}
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		if len(deliveries) > 0 {
+			if _, err := tx.NewInsert().Model(&deliveries).Exec(ctx); err != nil {
+				return fmt.Errorf("error inserting webhook deliveries: %w", err)
+			}
+		}
+
+		last := changes[n-1]
+		_, err = tx.NewUpdate().
+			Model((*webhookFeedState)(nil)).
+			Set("txid = ?::xid8", strconv.FormatUint(last.TxID, 10)).
+			Set("version_id = ?", last.ID).
+			Where("id = 1").
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error updating webhook feed state: %w", err)
+		}
+		return nil
+	})
+	return n, err
+}
+
+// selectWebhookChanges es SelectChanges con el estado anterior de cada
+// contribuyente, para poder filtrar por transiciones de estado.
+func selectWebhookChanges(ctx context.Context, db bun.IDB, since ChangeCursor) ([]*webhookChange, error) {
+	var changes []*webhookChange
+	err := db.NewSelect().
+		Model(&changes).
+		ColumnExpr("ch.*").
+		ColumnExpr(`(
+			SELECT prev.state FROM contributor_history prev
+			WHERE prev.contributor_id = ch.contributor_id AND prev.id < ch.id
+			ORDER BY prev.id DESC
+			LIMIT 1
+		) AS previous_state`).
+		Where("(ch.txid, ch.id) > (?::xid8, ?)", strconv.FormatUint(since.TxID, 10), since.ID).
+		Where("ch.txid < pg_snapshot_xmin(pg_current_snapshot())").
+		OrderExpr("ch.txid ASC, ch.id ASC").
+		Limit(webhookFeedBatchSize).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting webhook changes: %w", err)
+	}
+	return changes, nil
+}
+
+func newWebhookDelivery(
+	sub *WebhookSubscription, change *webhookChange, now time.Time,
+) (*WebhookDelivery, error) {
+	eventType := webhookEventType(change.ChangeType)
+	payload, err := json.Marshal(&webhookEvent{
+		Type:      eventType,
+		CreatedAt: change.ValidFrom,
+		Data:      change,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventType:      eventType,
+		Payload:        payload,
+		Status:         WebhookDeliveryPending,
+		NextAttemptAt:  now,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// deliverDue envía las entregas pendientes cuyo próximo intento ya venció.
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) error {
+	for {
+		var deliveries []*WebhookDelivery
+		err := d.app.DB().NewRaw(`
+			UPDATE webhook_deliveries
+			SET next_attempt_at = current_timestamp + make_interval(secs => ?)
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = ? AND next_attempt_at <= current_timestamp
+				ORDER BY next_attempt_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		`, webhookDeliveryLease.Seconds(), WebhookDeliveryPending, webhookDeliveryBatchSize).
+			Scan(ctx, &deliveries)
+		if err != nil {
+			return fmt.Errorf("error claiming webhook deliveries: %w", err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		for _, delivery := range deliveries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := d.deliver(ctx, delivery); err != nil {
+				log.Printf("error al guardar la entrega %d: %v", delivery.ID, err)
+			}
+		}
+	}
+}
+
+// deliver hace un intento de entrega y guarda su resultado. Si falla, la
+// entrega se reprograma con backoff exponencial o pasa a dead cuando se
+// agotan los intentos.
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *WebhookDelivery) error {
+	sub := new(WebhookSubscription)
+	err := d.app.DB().NewSelect().Model(sub).Where("id = ?", delivery.SubscriptionID).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			delivery.Status = WebhookDeliveryDead
+			delivery.LastError = "la suscripción ya no existe"
+			return d.saveDelivery(ctx, delivery, nil)
+		}
+		return err
+	}
+
+	attempt := &WebhookDeliveryAttempt{
+		DeliveryID:  delivery.ID,
+		AttemptedAt: time.Now().UTC(),
+	}
+	attempt.StatusCode, err = d.post(ctx, sub, delivery)
+	attempt.DurationMS = time.Since(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	switch {
+	case err == nil:
+		delivery.Status = WebhookDeliveryDelivered
+		delivery.DeliveredAt = bun.NullTime{Time: attempt.AttemptedAt}
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = WebhookDeliveryDead
+	default:
+		delivery.NextAttemptAt = attempt.AttemptedAt.Add(webhookBackoff(delivery.Attempts))
+	}
+
+	return d.saveDelivery(ctx, delivery, attempt)
+}
+
+func (d *WebhookDispatcher) post(
+	ctx context.Context, sub *WebhookSubscription, delivery *WebhookDelivery,
+) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "my-dgii-api-webhooks")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("X-Webhook-Signature", signWebhook(sub.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("el suscriptor respondió %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) saveDelivery(
+	ctx context.Context, delivery *WebhookDelivery, attempt *WebhookDeliveryAttempt,
+) error {
+	ctx = context.WithoutCancel(ctx)
+	return d.app.DB().RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model(delivery).
+			Column("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if attempt == nil {
+			return nil
+		}
+		_, err = tx.NewInsert().Model(attempt).Exec(ctx)
+		return err
+	})
+}
+
+// webhookBackoff es la espera antes del intento siguiente al número attempts:
+// 30s, 1m, 2m, 4m... hasta un máximo de 6h.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBackoffBase
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookBackoffMax {
+			return webhookBackoffMax
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,320 @@
+package contributors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"my-dgii-api/bunapp"
+	"my-dgii-api/httputil"
+	"my-dgii-api/httputil/httperror"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	maxWebhookBodySize        = 64 << 10
+	defaultWebhookDeliveries  = 20
+	maxWebhookDeliveriesLimit = 100
+)
+
+type WebhookHandler struct {
+	app *bunapp.App
+}
+
+// NewWebhookHandler crea un nuevo WebhookHandler.
+func NewWebhookHandler(app *bunapp.App) *WebhookHandler {
+	return &WebhookHandler{
+		app: app,
+	}
+}
+
+// webhookSubscriptionInput es el cuerpo para crear o reemplazar una
+// suscripción. Si Secret viene vacío al crear se genera uno.
+type webhookSubscriptionInput struct {
+	URL                string       `json:"url" validate:"required,max=2048"`
+	Secret             string       `json:"secret" validate:"max=256"`
+	Active             *bool        `json:"active"`
+	RNCs               []string     `json:"rncs"`
+	ChangeTypes        []ChangeType `json:"change_types" validate:"enum=insert|update|remove|restore|delete|undelete"`
+	FromStates         []string     `json:"from_states"`
+	ToStates           []string     `json:"to_states"`
+	EconomicActivities []string     `json:"economic_activities"`
+}
+
+func (in *webhookSubscriptionInput) apply(ctx context.Context, sub *WebhookSubscription) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return httperror.BadRequest("invalid_url", "url must be an absolute http or https URL")
+	}
+	if err := checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return httperror.InvalidField("url", "forbidden_host", "must resolve to a public address")
+	}
+
+	sub.URL = in.URL
+	if in.Secret != "" {
+		sub.Secret = in.Secret
+	}
+	sub.Active = in.Active == nil || *in.Active
+	// Matches compara con el RNC sin guiones, que es como se guarda.
+	var rncs []string
+	var errs []httperror.FieldError
+	for i, s := range in.RNCs {
+		id, _, err := parseRegistryRNC(s)
+		if err != nil {
+			errs = append(errs, httperror.FieldError{
+				Field:   fmt.Sprintf("rncs[%d]", i),
+				Code:    "rnc",
+				Message: "must be a valid RNC or cédula",
+			})
+			continue
+		}
+		rncs = append(rncs, id.String())
+	}
+	if len(errs) > 0 {
+		return httperror.Invalid(errs)
+	}
+	sub.RNCs = rncs
+	sub.ChangeTypes = in.ChangeTypes
+	sub.FromStates = in.FromStates
+	sub.ToStates = in.ToStates
+	sub.EconomicActivities = in.EconomicActivities
+	return nil
+}
+
+// Create maneja la solicitud para registrar una suscripción. El secreto solo
+// se devuelve en esta respuesta.
+func (h *WebhookHandler) Create(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in webhookSubscriptionInput
+	if err := httputil.BindJSON(w, req, &in); err != nil {
+		return err
+	}
+
+	sub := new(WebhookSubscription)
+	if err := in.apply(ctx, sub); err != nil {
+		return err
+	}
+	if sub.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return err
+		}
+		sub.Secret = secret
+	}
+
+	if _, err := h.app.DB().NewInsert().Model(sub).Exec(ctx); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", "/api/v1/webhooks/"+strconv.FormatInt(sub.ID, 10))
+	return httputil.JSON(w, sub, http.StatusCreated)
+}
+
+// List maneja la solicitud para obtener las suscripciones.
+func (h *WebhookHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var subs []*WebhookSubscription
+	count, err := h.app.DB().NewSelect().
+		Model(&subs).
+		Order("id ASC").
+		ScanAndCount(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows":       subs,
+		"totalCount": count,
+	})
+}
+
+// Get maneja la solicitud para obtener una suscripción.
+func (h *WebhookHandler) Get(w http.ResponseWriter, req bunrouter.Request) error {
+	sub, err := h.selectSubscription(req)
+	if err != nil {
+		return err
+	}
+	sub.Secret = ""
+
+	return bunrouter.JSON(w, sub)
+}
+
+// Update maneja la solicitud para reemplazar una suscripción. Sin secret se
+// conserva el actual.
+func (h *WebhookHandler) Update(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	sub, err := h.selectSubscription(req)
+	if err != nil {
+		return err
+	}
+
+	var in webhookSubscriptionInput
+	if err := httputil.BindJSON(w, req, &in); err != nil {
+		return err
+	}
+	if err := in.apply(ctx, sub); err != nil {
+		return err
+	}
+	sub.UpdatedAt = time.Now().UTC()
+
+	if _, err := h.app.DB().NewUpdate().Model(sub).WherePK().Exec(ctx); err != nil {
+		return err
+	}
+	sub.Secret = ""
+
+	return bunrouter.JSON(w, sub)
+}
+
+// Delete maneja la solicitud para eliminar una suscripción junto con sus
+// entregas.
+func (h *WebhookHandler) Delete(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	sub, err := h.selectSubscription(req)
+	if err != nil {
+		return err
+	}
+	if _, err := h.app.DB().NewDelete().Model(sub).WherePK().Exec(ctx); err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, nil, http.StatusNoContent)
+}
+
+// deliveriesQuery son los parámetros de GET /webhooks/:id/deliveries.
+type deliveriesQuery struct {
+	Limit  int    `query:"limit" validate:"min=1"`
+	Status string `query:"status"`
+}
+
+// Deliveries maneja la solicitud para obtener el registro de entregas de una
+// suscripción, de la más reciente a la más antigua. Se puede filtrar por
+// status.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	sub, err := h.selectSubscription(req)
+	if err != nil {
+		return err
+	}
+
+	in := deliveriesQuery{Limit: defaultWebhookDeliveries}
+	if err := httputil.BindQuery(req, &in); err != nil {
+		return err
+	}
+
+	var deliveries []*WebhookDelivery
+	q := h.app.DB().NewSelect().
+		Model(&deliveries).
+		Where("subscription_id = ?", sub.ID).
+		Order("id DESC").
+		Limit(min(in.Limit, maxWebhookDeliveriesLimit))
+	if in.Status != "" {
+		q = q.Where("status = ?", in.Status)
+	}
+	count, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"rows":       deliveries,
+		"totalCount": count,
+	})
+}
+
+// Delivery maneja la solicitud para obtener una entrega con sus intentos.
+func (h *WebhookHandler) Delivery(w http.ResponseWriter, req bunrouter.Request) error {
+	delivery, err := h.selectDelivery(req)
+	if err != nil {
+		return err
+	}
+	return bunrouter.JSON(w, delivery)
+}
+
+// RetryDelivery maneja la solicitud para volver a encolar una entrega en
+// estado dead.
+func (h *WebhookHandler) RetryDelivery(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	delivery, err := h.selectDelivery(req)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != WebhookDeliveryDead {
+		return httperror.New(http.StatusConflict, "delivery_not_dead",
+			"delivery %d is %s", delivery.ID, delivery.Status)
+	}
+
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	_, err = h.app.DB().NewUpdate().
+		Model(delivery).
+		Column("status", "attempts", "next_attempt_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return httputil.JSON(w, delivery, http.StatusAccepted)
+}
+
+func (h *WebhookHandler) selectSubscription(req bunrouter.Request) (*WebhookSubscription, error) {
+	id, err := req.Params().Int64("id")
+	if err != nil {
+		return nil, httperror.BadRequest("invalid_id", "id must be an integer")
+	}
+
+	sub := new(WebhookSubscription)
+	err = h.app.DB().NewSelect().Model(sub).Where("id = ?", id).Scan(req.Context())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, httperror.NotFound("webhook %d not found", id)
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (h *WebhookHandler) selectDelivery(req bunrouter.Request) (*WebhookDelivery, error) {
+	sub, err := h.selectSubscription(req)
+	if err != nil {
+		return nil, err
+	}
+	deliveryID, err := req.Params().Int64("delivery_id")
+	if err != nil {
+		return nil, httperror.BadRequest("invalid_id", "delivery_id must be an integer")
+	}
+
+	delivery := new(WebhookDelivery)
+	err = h.app.DB().NewSelect().
+		Model(delivery).
+		Relation("AttemptLog", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("wda.id ASC")
+		}).
+		Where("wd.id = ?", deliveryID).
+		Where("wd.subscription_id = ?", sub.ID).
+		Scan(req.Context())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, httperror.NotFound("delivery %d not found", deliveryID)
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,105 @@
+package contributors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errWebhookAddressForbidden = errors.New("la dirección del webhook no es pública")
+
+// forbiddenWebhookPrefixes son las redes especiales que no cubren los métodos
+// de netip.Addr.
+var forbiddenWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "esta" red
+	netip.MustParsePrefix("100.64.0.0/10"),  // NAT de operador
+	netip.MustParsePrefix("192.0.0.0/24"),   // asignaciones del IETF
+	netip.MustParsePrefix("198.18.0.0/15"),  // pruebas de rendimiento
+	netip.MustParsePrefix("240.0.0.0/4"),    // reservada y broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, puede apuntar a IPv4 privadas
+	netip.MustParsePrefix("64:ff9b:1::/48"), // traducción local
+	netip.MustParsePrefix("2001:db8::/32"),  // documentación
+}
+
+// forbiddenWebhookIP indica si ip es de loopback, privada, link-local (donde
+// están los metadatos de la nube, 169.254.169.254) o de otra red que no debe
+// recibir solicitudes del servidor.
+func forbiddenWebhookIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() ||
+		ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return true
+	}
+	for _, p := range forbiddenWebhookPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhookHost resuelve host y rechaza las direcciones prohibidas. Se usa
+// al registrar la suscripción; la entrega vuelve a comprobar la IP al
+// conectar porque el DNS puede cambiar después.
+func checkWebhookHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if forbiddenWebhookIP(ip) {
+			return errWebhookAddressForbidden
+		}
+		return nil
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("no se pudo resolver %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if forbiddenWebhookIP(ip) {
+			return errWebhookAddressForbidden
+		}
+	}
+	return nil
+}
+
+// newWebhookClient crea el cliente de las entregas. El dialer rechaza las IPs
+// prohibidas después de resolver el nombre, así que un cambio de DNS entre el
+// registro y la entrega no permite llegar a la red interna. No usa proxy ni
+// sigue redirecciones.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if forbiddenWebhookIP(ap.Addr()) {
+				return errWebhookAddressForbidden
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package contributors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestForbiddenWebhookIP(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"fd00:ec2::254", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"224.0.0.1", true},
+		{"8.8.8.8", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		ip := netip.MustParseAddr(tt.ip)
+		if got := forbiddenWebhookIP(ip); got != tt.forbidden {
+			t.Errorf("forbiddenWebhookIP(%s) = %v, want %v", tt.ip, got, tt.forbidden)
+		}
+	}
+}
+
+func TestCheckWebhookHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "localhost"} {
+		if err := checkWebhookHost(ctx, host); err == nil {
+			t.Errorf("checkWebhookHost(%q) = nil, want error", host)
+		}
+	}
+	if err := checkWebhookHost(ctx, "8.8.8.8"); err != nil {
+		t.Errorf("checkWebhookHost(8.8.8.8) = %v, want nil", err)
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := newWebhookClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, errWebhookAddressForbidden) {
+		t.Fatalf("got %v, want errWebhookAddressForbidden", err)
+	}
+}
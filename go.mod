@@ -4,13 +4,13 @@ go 1.24
 
 require (
 	github.com/benbjohnson/clock v1.3.5
-	github.com/cristianuser/go-bun-webserver v0.0.0-20230522000147-1d3a15941cea
 	github.com/go-co-op/gocron v1.37.0
 	github.com/uptrace/bun/driver/pgdriver v1.1.13
 	github.com/uptrace/bun/extra/bundebug v1.1.13
 	github.com/uptrace/bunrouter/extra/bunrouterotel v1.0.20
 	github.com/uptrace/bunrouter/extra/reqlog v1.0.20
 	go4.org v0.0.0-20230225012048-214862532bf5
+	golang.org/x/text v0.9.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -0,0 +1,410 @@
+package httputil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"my-dgii-api/httputil/httperror"
+	"my-dgii-api/rnc"
+
+	"github.com/uptrace/bunrouter"
+)
+
+// DefaultMaxBodySize is the body limit of BindJSON for routes that do not set
+// one with BodyLimit.
+const DefaultMaxBodySize = 1 << 20
+
+type bodyLimitKey struct{}
+
+// BodyLimit returns a middleware that sets the body limit used by BindJSON on
+// the routes it wraps.
+func BodyLimit(maxBytes int64) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			ctx := context.WithValue(req.Context(), bodyLimitKey{}, maxBytes)
+			return next(w, req.WithContext(ctx))
+		}
+	}
+}
+
+func bodyLimit(ctx context.Context) int64 {
+	if n, ok := ctx.Value(bodyLimitKey{}).(int64); ok {
+		return n
+	}
+	return DefaultMaxBodySize
+}
+
+// BindJSON decodes the JSON body into dst and validates it with Validate.
+// Unknown fields are rejected.
+func BindJSON(
+	w http.ResponseWriter,
+	req bunrouter.Request,
+	dst interface{},
+) error {
+	if err := UnmarshalJSON(w, req, dst, bodyLimit(req.Context())); err != nil {
+		if httpErr := httperror.From(err); httpErr.Status != http.StatusInternalServerError {
+			return httpErr
+		}
+		return httperror.BadRequest("invalid_json", err.Error())
+	}
+	return Validate(dst)
+}
+
+// BindQuery decodes the query string into the fields of dst tagged with
+// `query:"name"` and validates it with Validate. Fields whose parameter is
+// missing keep their value, so defaults can be set before calling it.
+// Supported types are strings, bools, integers, floats, time.Time (a date or
+// RFC 3339), string slices, which accept repeated or comma-separated values,
+// and pointers to those, which stay nil when the parameter is missing.
+func BindQuery(req bunrouter.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	values := req.URL.Query()
+
+	var errs []httperror.FieldError
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		name := sf.Tag.Get("query")
+		if name == "" || name == "-" {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			continue
+		}
+		if err := setQueryField(v.Field(i), values, name); err != nil {
+			errs = append(errs, httperror.FieldError{
+				Field:   name,
+				Code:    "invalid_type",
+				Message: err.Error(),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return httperror.Invalid(errs)
+	}
+	return Validate(dst)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setQueryField(f reflect.Value, values url.Values, name string) error {
+	if f.Kind() == reflect.Pointer {
+		elem := reflect.New(f.Type().Elem())
+		if err := setQueryField(elem.Elem(), values, name); err != nil {
+			return err
+		}
+		f.Set(elem)
+		return nil
+	}
+
+	s := values.Get(name)
+
+	if f.Type() == timeType {
+		layout := time.RFC3339
+		if len(s) == len(time.DateOnly) {
+			layout = time.DateOnly
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", name)
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		f.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s must be a number", name)
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported query field type %s", f.Type())
+		}
+		var list []string
+		for _, v := range values[name] {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+		slice := reflect.MakeSlice(f.Type(), len(list), len(list))
+		for i, item := range list {
+			slice.Index(i).SetString(item)
+		}
+		f.Set(slice)
+	default:
+		return fmt.Errorf("unsupported query field type %s", f.Type())
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// A RuleFunc checks a single non-zero value and returns a message if it is
+// invalid.
+type RuleFunc func(v reflect.Value, param string) (msg string)
+
+var rules = map[string]RuleFunc{
+	"rnc": func(v reflect.Value, _ string) string {
+		if v.Kind() != reflect.String || !rnc.Valid(v.String()) {
+			return "must be a valid RNC or cédula"
+		}
+		return ""
+	},
+}
+
+// RegisterRule adds a custom validation rule. It must be called during
+// initialization.
+func RegisterRule(name string, fn RuleFunc) {
+	rules[name] = fn
+}
+
+// Validate checks the `validate` tags of dst, a pointer to a struct, and
+// returns a 422 httperror listing every invalid field. Rules are separated by
+// commas:
+//
+//	required      the value must not be zero
+//	min=N, max=N  length of strings (in characters) and slices, or the value of numbers
+//	enum=a|b      the value must be one of the options
+//	regex=expr    the value must match expr; it must be the last rule
+//	rnc           the value must be a valid RNC or cédula
+//
+// Except required, rules skip zero values; min and max still check zero
+// numbers, so optional numeric fields need a pointer or a default set before
+// binding. enum, regex and custom rules apply to each element of a slice.
+// Nested structs are validated too.
+func Validate(dst interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(dst))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []httperror.FieldError
+	validateStruct(v, "", &errs)
+	if len(errs) > 0 {
+		return httperror.Invalid(errs)
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *[]httperror.FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+
+		// Embedded structs are validated even if their type is unexported,
+		// since encoding/json decodes their fields too.
+		if sf.Anonymous && f.Kind() == reflect.Struct {
+			validateStruct(f, prefix, errs)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(sf)
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			validateField(f, name, tag, errs)
+		}
+
+		if f.Kind() == reflect.Pointer && !f.IsNil() {
+			f = f.Elem()
+		}
+		if f.Kind() == reflect.Struct && f.Type() != timeType {
+			validateStruct(f, name+".", errs)
+		}
+	}
+}
+
+func validateField(f reflect.Value, name, tag string, errs *[]httperror.FieldError) {
+	fail := func(field, code, msg string) {
+		*errs = append(*errs, httperror.FieldError{Field: field, Code: code, Message: msg})
+	}
+
+	if f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			if hasRule(tag, "required") {
+				fail(name, "required", "is required")
+			}
+			return
+		}
+		f = f.Elem()
+	}
+
+	for _, r := range parseRules(tag) {
+		if r.name == "required" {
+			if f.IsZero() || (f.Kind() == reflect.Slice && f.Len() == 0) {
+				fail(name, "required", "is required")
+				return
+			}
+			continue
+		}
+		if f.IsZero() && !(isNumber(f) && (r.name == "min" || r.name == "max")) {
+			continue
+		}
+
+		switch r.name {
+		case "min", "max":
+			n, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				panic(fmt.Sprintf("httputil: invalid %s rule on %s: %q", r.name, name, r.param))
+			}
+			if msg := checkBound(f, r.name, n); msg != "" {
+				fail(name, r.name, msg)
+			}
+		default:
+			if f.Kind() == reflect.Slice {
+				for i := 0; i < f.Len(); i++ {
+					if msg := checkRule(f.Index(i), r, name); msg != "" {
+						fail(fmt.Sprintf("%s[%d]", name, i), r.name, msg)
+					}
+				}
+				continue
+			}
+			if msg := checkRule(f, r, name); msg != "" {
+				fail(name, r.name, msg)
+			}
+		}
+	}
+}
+
+func checkBound(f reflect.Value, rule string, n float64) string {
+	var size float64
+	unit := ""
+	switch f.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(f.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		size = float64(f.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(f.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = f.Float()
+	default:
+		return ""
+	}
+
+	limit := strconv.FormatFloat(n, 'f', -1, 64)
+	if rule == "min" && size < n {
+		return "must be at least " + limit + unit
+	}
+	if rule == "max" && size > n {
+		return "must be at most " + limit + unit
+	}
+	return ""
+}
+
+func isNumber(f reflect.Value) bool {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func checkRule(v reflect.Value, r rule, name string) string {
+	switch r.name {
+	case "enum":
+		options := strings.Split(r.param, "|")
+		if !slices.Contains(options, fmt.Sprint(v)) {
+			return "must be one of " + strings.Join(options, ", ")
+		}
+		return ""
+	case "regex":
+		if v.Kind() == reflect.String && !compileRegex(r.param).MatchString(v.String()) {
+			return "has an invalid format"
+		}
+		return ""
+	}
+
+	fn, ok := rules[r.name]
+	if !ok {
+		panic(fmt.Sprintf("httputil: unknown validation rule %q on %s", r.name, name))
+	}
+	return fn(v, r.param)
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+func parseRules(tag string) []rule {
+	var list []rule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(part, "=")
+		list = append(list, rule{name: name, param: param})
+	}
+	return list
+}
+
+func hasRule(tag, name string) bool {
+	for _, r := range parseRules(tag) {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var regexCache sync.Map
+
+func compileRegex(expr string) *regexp.Regexp {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	regexCache.Store(expr, re)
+	return re
+}
+
+// fieldName is the name clients use for the field: the query or JSON tag, or
+// the Go name.
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"query", "json"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
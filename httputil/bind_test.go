@@ -0,0 +1,247 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-dgii-api/httputil/httperror"
+
+	"github.com/uptrace/bunrouter"
+)
+
+type bindAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type bindBase struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type bindInput struct {
+	bindBase
+
+	Age     *int         `json:"age" validate:"min=18,max=99"`
+	Score   *float64     `json:"score" validate:"min=0.1,max=1"`
+	Kind    string       `json:"kind" validate:"enum=a|b"`
+	Tags    []string     `json:"tags" validate:"max=2,enum=x|y"`
+	Code    string       `json:"code" validate:"regex=^[A-Z]{2},[0-9]+$"`
+	RNC     string       `json:"rnc" validate:"rnc"`
+	Address *bindAddress `json:"address"`
+}
+
+func newBindRequest(method, target, body string) bunrouter.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	return bunrouter.NewRequest(req)
+}
+
+// fieldCodes returns field=code for each validation error, or nil if err is
+// not a 422.
+func fieldCodes(err error) []string {
+	var httpErr httperror.Error
+	if !errors.As(err, &httpErr) || httpErr.Status != http.StatusUnprocessableEntity {
+		return nil
+	}
+	codes := make([]string, len(httpErr.Errors))
+	for i, e := range httpErr.Errors {
+		codes[i] = e.Field + "=" + e.Code
+	}
+	return codes
+}
+
+func TestBindJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		codes  []string
+	}{
+		{name: "valid", body: `{"name":"ana","age":30,"address":{"city":"SD"}}`},
+		{name: "unknown field", body: `{"name":"ana","version":2}`, status: http.StatusBadRequest},
+		{name: "malformed", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "wrong type", body: `{"name":"ana","age":"30"}`, status: http.StatusBadRequest},
+		{
+			name:   "embedded and nested",
+			body:   `{"address":{}}`,
+			status: http.StatusUnprocessableEntity,
+			codes:  []string{"name=required", "address.city=required"},
+		},
+		{
+			name:   "bounds",
+			body:   `{"name":"ana maria","age":17,"score":0}`,
+			status: http.StatusUnprocessableEntity,
+			codes:  []string{"name=max", "age=min", "score=min"},
+		},
+		{
+			name:   "rules",
+			body:   `{"name":"ana","kind":"c","tags":["x","z"],"code":"A1","rnc":"101850042"}`,
+			status: http.StatusUnprocessableEntity,
+			codes:  []string{"kind=enum", "tags[1]=enum", "code=regex", "rnc=rnc"},
+		},
+		{
+			name:   "regex with comma",
+			body:   `{"name":"ana","code":"DO,123","rnc":"1-01-85004-3"}`,
+			status: 0,
+		},
+		{
+			name:   "slice length",
+			body:   `{"name":"ana","tags":["x","y","x"]}`,
+			status: http.StatusUnprocessableEntity,
+			codes:  []string{"tags=max"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var in bindInput
+			err := BindJSON(httptest.NewRecorder(), newBindRequest(http.MethodPost, "/", test.body), &in)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("BindJSON() = %v", err)
+				}
+				return
+			}
+			if got := httperror.From(err).Status; got != test.status {
+				t.Fatalf("BindJSON() status = %d (%v), want %d", got, err, test.status)
+			}
+			if test.codes != nil && !reflect.DeepEqual(fieldCodes(err), test.codes) {
+				t.Errorf("BindJSON() errors = %v, want %v", fieldCodes(err), test.codes)
+			}
+		})
+	}
+}
+
+func TestBindJSONBodyLimit(t *testing.T) {
+	handler := func(w http.ResponseWriter, req bunrouter.Request) error {
+		var in bindInput
+		return BindJSON(w, req, &in)
+	}
+	body := `{"name":"ana","kind":"` + strings.Repeat("a", 64) + `"}`
+
+	err := BodyLimit(32)(handler)(httptest.NewRecorder(), newBindRequest(http.MethodPost, "/", body))
+	if got := httperror.From(err); got.Status != http.StatusRequestEntityTooLarge || got.Code != "body_too_large" {
+		t.Errorf("BindJSON() = %+v, want 413 body_too_large", got)
+	}
+
+	// Without BodyLimit the limit is DefaultMaxBodySize.
+	err = handler(httptest.NewRecorder(), newBindRequest(http.MethodPost, "/", body))
+	if fieldCodes(err) == nil || !reflect.DeepEqual(fieldCodes(err), []string{"kind=enum"}) {
+		t.Errorf("BindJSON() = %v, want only kind=enum", err)
+	}
+}
+
+type bindQuery struct {
+	Q      string    `query:"q" validate:"required,min=3"`
+	Limit  int       `query:"limit" validate:"min=1,max=100"`
+	Offset *int      `query:"offset" validate:"min=0"`
+	Score  float64   `query:"score"`
+	Exact  bool      `query:"exact"`
+	States []string  `query:"state" validate:"enum=A|B|C"`
+	Since  time.Time `query:"since"`
+	Ignore string
+}
+
+func TestBindQuery(t *testing.T) {
+	offset := func(n int) *int { return &n }
+	tests := []struct {
+		query string
+		want  bindQuery
+		codes []string
+	}{
+		{
+			query: "q=abc",
+			want:  bindQuery{Q: "abc", Limit: 20},
+		},
+		{
+			query: "q=abc&limit=5&offset=0&score=0.5&exact=true&state=A,B&state=C&since=2024-01-02&Ignore=x",
+			want: bindQuery{
+				Q: "abc", Limit: 5, Offset: offset(0), Score: 0.5, Exact: true,
+				States: []string{"A", "B", "C"},
+				Since:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			query: "q=abc&since=2024-01-02T03:04:05Z",
+			want:  bindQuery{Q: "abc", Limit: 20, Since: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		{
+			query: "limit=x&offset=-&exact=maybe&score=a&since=ayer",
+			codes: []string{"limit=invalid_type", "offset=invalid_type", "score=invalid_type",
+				"exact=invalid_type", "since=invalid_type"},
+		},
+		{
+			query: "q=ab&limit=0&offset=-1&state=D",
+			codes: []string{"q=min", "limit=min", "offset=min", "state[0]=enum"},
+		},
+		{
+			query: "limit=101",
+			codes: []string{"q=required", "limit=max"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			in := bindQuery{Limit: 20}
+			err := BindQuery(newBindRequest(http.MethodGet, "/?"+test.query, ""), &in)
+			if test.codes != nil {
+				if !reflect.DeepEqual(fieldCodes(err), test.codes) {
+					t.Errorf("BindQuery() errors = %v (%v), want %v", fieldCodes(err), err, test.codes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BindQuery() = %v", err)
+			}
+			if !reflect.DeepEqual(in, test.want) {
+				t.Errorf("BindQuery() = %+v, want %+v", in, test.want)
+			}
+		})
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("even", func(v reflect.Value, _ string) string {
+		if v.Int()%2 != 0 {
+			return "must be even"
+		}
+		return ""
+	})
+	defer delete(rules, "even")
+
+	var in struct {
+		N int `json:"n" validate:"even"`
+	}
+	in.N = 3
+	if codes := fieldCodes(Validate(&in)); !reflect.DeepEqual(codes, []string{"n=even"}) {
+		t.Errorf("Validate() errors = %v, want [n=even]", codes)
+	}
+	in.N = 4
+	if err := Validate(&in); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidatePanicsOnBadTags(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			S string `validate:"unknown"`
+		}{S: "x"},
+		&struct {
+			S string `validate:"min=x"`
+		}{S: "x"},
+	}
+	for _, dst := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Validate(%T) did not panic", dst)
+				}
+			}()
+			_ = Validate(dst)
+		}()
+	}
+}
@@ -1,17 +1,28 @@
 package httperror
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/uptrace/bun/driver/pgdriver"
 )
 
+// StatusClientClosedRequest is the nginx convention for a request the client
+// abandoned before the response was ready.
+const StatusClientClosedRequest = 499
+
 var (
 	errEOF      = BadRequest("eof", "EOF reading HTTP request body")
 	ErrNotFound = NotFound("not found")
 	ErrInternal = New(http.StatusInternalServerError, "internal", "internal server error")
+
+	errClientClosed = New(StatusClientClosedRequest, "client_closed_request", "client closed the request")
+	errTimeout      = New(http.StatusServiceUnavailable, "timeout", "the request took too long")
 )
 
 func NotFound(msg string, args ...interface{}) Error {
@@ -24,17 +35,51 @@ func BadRequest(code, msg string, args ...interface{}) Error {
 
 //------------------------------------------------------------------------------
 
+// Error is an RFC 7807 problem. Code is stable and meant for clients to
+// switch on; Message is for humans and may change.
 type Error struct {
-	Status  int    `json:"status"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"detail"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Errors lists the invalid fields of a request that failed validation.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single field of a request is invalid. Field is
+// the JSON or query parameter name, with a dotted path for nested fields.
+type FieldError struct {
+	Field   string `json:"field"`
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
 
+// Invalid returns a 422 problem that lists every invalid field.
+func Invalid(errs []FieldError) Error {
+	e := New(http.StatusUnprocessableEntity, "validation_failed", "the request has invalid fields")
+	e.Errors = errs
+	return e
+}
+
+// InvalidField returns a 422 problem for a single invalid field.
+func InvalidField(field, code, msg string, args ...interface{}) Error {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	return Invalid([]FieldError{{Field: field, Code: code, Message: msg}})
+}
+
 func New(status int, code, msg string, args ...interface{}) Error {
 	if len(args) > 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
 	return Error{
+		Type:    "about:blank",
+		Title:   statusText(status),
 		Status:  status,
 		Code:    code,
 		Message: msg,
@@ -45,22 +90,89 @@ func (e Error) Error() string {
 	return e.Message
 }
 
+// Write sends the problem as application/problem+json.
+func (e Error) Write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.Status)
+	return json.NewEncoder(w).Encode(e)
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
 //------------------------------------------------------------------------------
 
+// From maps err, or any error it wraps, to a problem. Unknown errors become a
+// 500 without leaking their message. EOF is the exception: only an unwrapped
+// io.EOF or io.ErrUnexpectedEOF is reported as a bad request body.
 func From(err error) Error {
-	switch err {
-	case io.EOF:
+	var httpErr Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	switch {
+	// Only a bare EOF comes from decoding the request body; a wrapped one is
+	// usually a dropped database connection or a failed read of a file.
+	case err == io.EOF, err == io.ErrUnexpectedEOF:
 		return errEOF
-	case sql.ErrNoRows:
+	case errors.Is(err, sql.ErrNoRows):
 		return ErrNotFound
+	case errors.Is(err, context.Canceled):
+		return errClientClosed
+	case errors.Is(err, context.DeadlineExceeded):
+		return errTimeout
 	}
 
-	switch err := err.(type) {
-	case Error:
-		return err
-	case *json.SyntaxError:
-		return BadRequest("json_syntax", err.Error())
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return BadRequest("json_syntax", syntaxErr.Error())
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return BadRequest("json_type", "%s must be %s", typeErr.Field, typeErr.Type)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return New(http.StatusRequestEntityTooLarge, "body_too_large",
+			"request body must not exceed %d bytes", maxBytesErr.Limit)
+	}
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return fromPG(pgErr)
 	}
 
 	return ErrInternal
 }
+
+// fromPG maps constraint violations to client errors. Constraint and column
+// names are part of the schema, so they are safe to report.
+func fromPG(err pgdriver.Error) Error {
+	constraint := err.Field('n')
+	column := err.Field('c')
+
+	switch err.Field('C') {
+	case "23505":
+		return New(http.StatusConflict, "unique_violation",
+			"a row with the same value already exists (%s)", constraint)
+	case "23P01":
+		return New(http.StatusConflict, "exclusion_violation",
+			"the row conflicts with an existing row (%s)", constraint)
+	case "23503":
+		return New(http.StatusUnprocessableEntity, "foreign_key_violation",
+			"a referenced row does not exist (%s)", constraint)
+	case "23502":
+		return New(http.StatusUnprocessableEntity, "not_null_violation",
+			"%s is required", column)
+	case "23514":
+		return New(http.StatusUnprocessableEntity, "check_violation",
+			"a value does not satisfy %s", constraint)
+	case "57014":
+		return errTimeout
+	}
+	return ErrInternal
+}
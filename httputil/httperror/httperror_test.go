@@ -0,0 +1,33 @@
+package httperror
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{io.EOF, http.StatusBadRequest},
+		{io.ErrUnexpectedEOF, http.StatusBadRequest},
+		{fmt.Errorf("error selecting contributor by RNC: %w", io.EOF), http.StatusInternalServerError},
+		{fmt.Errorf("error al leer el archivo: %w", io.ErrUnexpectedEOF), http.StatusInternalServerError},
+		{fmt.Errorf("select: %w", sql.ErrNoRows), http.StatusNotFound},
+		{fmt.Errorf("select: %w", context.Canceled), StatusClientClosedRequest},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{fmt.Errorf("wrapped: %w", BadRequest("code", "msg")), http.StatusBadRequest},
+		{&http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		if got := From(test.err).Status; got != test.status {
+			t.Errorf("From(%v).Status = %d, want %d", test.err, got, test.status)
+		}
+	}
+}
@@ -23,13 +23,14 @@ func JSON(w http.ResponseWriter, value interface{}, s int) error {
 	if s == 0 {
 		s = http.StatusOK
 	}
-	w.WriteHeader(s)
 
 	if value == nil {
+		w.WriteHeader(s)
 		return nil
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(s)
 
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(value); err != nil {
@@ -38,14 +39,3 @@ func JSON(w http.ResponseWriter, value interface{}, s int) error {
 
 	return nil
 }
-
-func BindJSON(
-	w http.ResponseWriter,
-	req bunrouter.Request,
-	dst interface{},
-) error {
-	if err := UnmarshalJSON(w, req, dst, 10); err != nil {
-		return err
-	}
-	return nil
-}
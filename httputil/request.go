@@ -1,8 +1,11 @@
 package httputil
 
 import (
+	"context"
 	"net/http"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 func GetUserIP(r *http.Request) string {
@@ -54,3 +57,34 @@ func GetUserBrowserAndOS(r *http.Request) (browser, os string) {
 
 	return browser, os
 }
+
+type requestIDKey struct{}
+
+// RequestIDHeader is the header used to propagate the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID returns a copy of ctx that carries the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns the client's request ID if it looks safe to echo back,
+// or a new random one otherwise.
+func NewRequestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		return uuid.NewString()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
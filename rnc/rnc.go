@@ -0,0 +1,133 @@
+// Package rnc valida y da formato a los identificadores de contribuyentes de
+// la DGII: el RNC de 9 dígitos y la cédula de 11.
+package rnc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kind es el tipo de identificador.
+type Kind string
+
+const (
+	KindRNC    Kind = "rnc"
+	KindCedula Kind = "cedula"
+)
+
+var (
+	ErrInvalidCharacters = errors.New("el identificador solo puede tener dígitos, guiones y espacios")
+	ErrInvalidLength     = errors.New("el identificador debe tener 9 (RNC) u 11 (cédula) dígitos")
+	ErrInvalidCheckDigit = errors.New("el dígito verificador no es correcto")
+)
+
+// ID es un RNC o cédula válido y normalizado.
+type ID struct {
+	Number string
+	Kind   Kind
+}
+
+// String devuelve el número sin guiones, que es como se guarda.
+func (id ID) String() string {
+	return id.Number
+}
+
+// Formatted devuelve el número con guiones: 1-01-12345-6 para un RNC y
+// 001-1234567-8 para una cédula.
+func (id ID) Formatted() string {
+	switch id.Kind {
+	case KindRNC:
+		return id.Number[:1] + "-" + id.Number[1:3] + "-" + id.Number[3:8] + "-" + id.Number[8:]
+	case KindCedula:
+		return id.Number[:3] + "-" + id.Number[3:10] + "-" + id.Number[10:]
+	default:
+		return id.Number
+	}
+}
+
+var normalizer = strings.NewReplacer("-", "", " ", "")
+
+// Normalize quita los guiones y los espacios de s.
+func Normalize(s string) string {
+	return normalizer.Replace(strings.TrimSpace(s))
+}
+
+// Parse normaliza s y comprueba la longitud y el dígito verificador. La DGII
+// emitió algunos números cuyo dígito verificador no cumple el algoritmo; para
+// que quien lea el padrón pueda aceptarlos, si solo falla el dígito Parse
+// devuelve el ID junto con ErrInvalidCheckDigit.
+func Parse(s string) (ID, error) {
+	s = Normalize(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return ID{}, ErrInvalidCharacters
+		}
+	}
+
+	switch len(s) {
+	case 9:
+		id := ID{Number: s, Kind: KindRNC}
+		if rncCheckDigit(s[:8]) != s[8]-'0' {
+			return id, ErrInvalidCheckDigit
+		}
+		return id, nil
+	case 11:
+		id := ID{Number: s, Kind: KindCedula}
+		if cedulaCheckDigit(s[:10]) != s[10]-'0' {
+			return id, ErrInvalidCheckDigit
+		}
+		return id, nil
+	default:
+		return ID{}, ErrInvalidLength
+	}
+}
+
+// Valid indica si s es un RNC o una cédula válido, con o sin guiones.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Format devuelve s con guiones si es válido, o s sin cambios si no lo es.
+func Format(s string) string {
+	id, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	return id.Formatted()
+}
+
+// rncCheckDigit calcula el dígito verificador de un RNC con el módulo 11
+// ponderado que publica la DGII.
+func rncCheckDigit(digits string) byte {
+	weights := [8]int{7, 9, 8, 6, 5, 4, 3, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(digits[i]-'0') * w
+	}
+	switch r := sum % 11; r {
+	case 0:
+		return 2
+	case 1:
+		return 1
+	default:
+		return byte(11 - r)
+	}
+}
+
+// cedulaCheckDigit calcula el dígito verificador de una cédula con el
+// algoritmo de Luhn.
+func cedulaCheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte((10 - sum%10) % 10)
+}
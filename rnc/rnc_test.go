@@ -0,0 +1,77 @@
+package rnc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		number string
+		kind   Kind
+		err    error
+	}{
+		{in: "101850043", number: "101850043", kind: KindRNC},
+		{in: "1-01-85004-3", number: "101850043", kind: KindRNC},
+		{in: " 1 01 85004 3 ", number: "101850043", kind: KindRNC},
+		{in: "131246796", number: "131246796", kind: KindRNC},
+		{in: "00113918205", number: "00113918205", kind: KindCedula},
+		{in: "001-1391820-5", number: "00113918205", kind: KindCedula},
+
+		{in: "101850042", number: "101850042", kind: KindRNC, err: ErrInvalidCheckDigit},
+		{in: "1-01-85004-2", number: "101850042", kind: KindRNC, err: ErrInvalidCheckDigit},
+		{in: "00113918204", number: "00113918204", kind: KindCedula, err: ErrInvalidCheckDigit},
+		{in: "1018A0043", err: ErrInvalidCharacters},
+		{in: "1.01.85004.3", err: ErrInvalidCharacters},
+		{in: "", err: ErrInvalidLength},
+		{in: "10185004", err: ErrInvalidLength},
+		{in: "1018500430", err: ErrInvalidLength},
+		{in: "001139182050", err: ErrInvalidLength},
+	}
+
+	for _, test := range tests {
+		id, err := Parse(test.in)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", test.in, err, test.err)
+			continue
+		}
+		if id.Number != test.number || id.Kind != test.kind {
+			t.Errorf("Parse(%q) = %+v, want {Number:%s Kind:%s}", test.in, id, test.number, test.kind)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"101850043", "1-01-85004-3"},
+		{"1-01-85004-3", "1-01-85004-3"},
+		{"00113918205", "001-1391820-5"},
+		{"001 1391820 5", "001-1391820-5"},
+		// Los inválidos se devuelven sin cambios.
+		{"101850042", "101850042"},
+		{"abc", "abc"},
+	}
+
+	for _, test := range tests {
+		if got := Format(test.in); got != test.want {
+			t.Errorf("Format(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, s := range []string{"101850043", "1-01-85004-3", "00113918205", "001-1391820-5"} {
+		if !Valid(s) {
+			t.Errorf("Valid(%q) = false, want true", s)
+		}
+	}
+	for _, s := range []string{"101850042", "00113918204", "1018A0043", "123"} {
+		if Valid(s) {
+			t.Errorf("Valid(%q) = true, want false", s)
+		}
+	}
+}